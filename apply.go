@@ -0,0 +1,249 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultFieldManager is the field manager used for server-side apply when
+// WithServerSideApply is given an empty one.
+const DefaultFieldManager = "k8stest"
+
+// conflictManagerPattern extracts the conflicting manager from the message of
+// a field manager conflict cause, e.g. `conflict with "kubectl" using apps/v1`.
+var conflictManagerPattern = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// FieldConflict is a field of an applied object that is owned by another
+// field manager.
+type FieldConflict struct {
+	Field   string
+	Manager string
+	Message string
+}
+
+// FieldConflictError is returned by Create and Update in server-side apply
+// mode when fields of an object are owned by other field managers. It wraps
+// the Conflict error returned by the API server.
+type FieldConflictError struct {
+	Kind         string
+	Namespace    string
+	Name         string
+	FieldManager string
+	Conflicts    []FieldConflict
+	Err          error
+}
+
+func (e *FieldConflictError) Error() string {
+	fields := make([]string, 0, len(e.Conflicts))
+	for _, conflict := range e.Conflicts {
+		fields = append(fields, fmt.Sprintf("%s (owned by %q)", conflict.Field, conflict.Manager))
+	}
+
+	return fmt.Sprintf("failed to apply %s %s/%s as %q, conflicting fields: %s",
+		e.Kind, e.Namespace, e.Name, e.FieldManager, strings.Join(fields, ", "))
+}
+
+func (e *FieldConflictError) Unwrap() error {
+	return e.Err
+}
+
+// WithServerSideApply returns an Option that makes Create and Update use
+// server-side apply with the given field manager instead of delete-then-create
+// and full updates. Existing objects are kept, so tests can be re-run against
+// them, and fields owned by other managers fail with a FieldConflictError.
+func WithServerSideApply(fieldManager string) Option {
+	return func(_ *testing.T, r *Resources) {
+		r.ServerSideApply = true
+		r.FieldManager = fieldManager
+	}
+}
+
+// WithForceOwnership returns an Option that makes server-side apply take over
+// fields owned by other field managers instead of failing.
+func WithForceOwnership() Option {
+	return func(_ *testing.T, r *Resources) {
+		r.ForceOwnership = true
+	}
+}
+
+func (r *Resources) fieldManager() string {
+	if r.FieldManager == "" {
+		return DefaultFieldManager
+	}
+
+	return r.FieldManager
+}
+
+// applyAll applies all tracked resources in the order Create creates them.
+func (r *Resources) applyAll(ctx context.Context) error {
+	for _, obj := range r.orderedObjects() {
+		if err := r.apply(ctx, obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appliedState is what apply last sent for an object, the intent of the test,
+// and the state the server returned for it.
+type appliedState struct {
+	intent *unstructured.Unstructured
+	server *unstructured.Unstructured
+}
+
+// apply sends obj as a server-side apply patch and writes the result back into
+// obj. Server-populated metadata is left out so that objects read from the
+// cluster earlier can be applied again. Once obj was applied, only the fields
+// of the previous intent and the fields changed since are sent, so that the
+// field manager does not take over defaults and fields set by controllers.
+func (r *Resources) apply(ctx context.Context, obj client.Object) error {
+	applied, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	if state, ok := r.applied[obj]; ok {
+		fields, _ := intendedFields(applied.Object, state.server.Object, state.intent.Object, true)
+		applied.Object, _ = fields.(map[string]any)
+	}
+
+	intent := applied.DeepCopy()
+
+	opts := []client.ApplyOption{client.FieldOwner(r.fieldManager())}
+	if r.ForceOwnership {
+		opts = append(opts, client.ForceOwnership)
+	}
+
+	err = r.TestClients.K8sClient.Apply(ctx, client.ApplyConfigurationFromUnstructured(applied), opts...)
+	if err != nil {
+		return r.applyError(applied, err)
+	}
+
+	if err := writeBackUnstructured(obj, applied); err != nil {
+		return err
+	}
+
+	server, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	if r.applied == nil {
+		r.applied = map[client.Object]*appliedState{}
+	}
+
+	r.applied[obj] = &appliedState{intent: intent, server: server}
+
+	return nil
+}
+
+// syncApplied remembers the state written back into obj, e.g. by Refresh, as
+// the state returned by the server, if obj was applied before.
+func (r *Resources) syncApplied(obj client.Object) error {
+	state, ok := r.applied[obj]
+	if !ok {
+		return nil
+	}
+
+	server, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	state.server = server
+
+	return nil
+}
+
+// intendedFields returns the parts of current that are in intent, or that
+// differ from server because the test changed them. Fields only the API server
+// or controllers set are left out. List elements are matched by index. The
+// result is false if nothing of current is intended.
+func intendedFields(current, server, intent any, intended bool) (any, bool) {
+	switch value := current.(type) {
+	case map[string]any:
+		serverFields, _ := server.(map[string]any)
+		intentFields, _ := intent.(map[string]any)
+		fields := map[string]any{}
+
+		for key, field := range value {
+			intentField, inIntent := intentFields[key]
+			if field, ok := intendedFields(field, serverFields[key], intentField, inIntent); ok {
+				fields[key] = field
+			}
+		}
+
+		return fields, intended || len(fields) > 0
+	case []any:
+		if !intended && reflect.DeepEqual(value, server) {
+			return nil, false
+		}
+
+		serverElements, _ := server.([]any)
+		intentElements, _ := intent.([]any)
+		elements := make([]any, len(value))
+
+		for i, element := range value {
+			elements[i], _ = intendedFields(element, elementAt(serverElements, i), elementAt(intentElements, i), true)
+		}
+
+		return elements, true
+	default:
+		return current, intended || !reflect.DeepEqual(current, server)
+	}
+}
+
+func elementAt(elements []any, i int) any {
+	if i >= len(elements) {
+		return nil
+	}
+
+	return elements[i]
+}
+
+// applyError turns field manager conflicts into a FieldConflictError.
+func (r *Resources) applyError(obj *unstructured.Unstructured, err error) error {
+	var statusErr apierrors.APIStatus
+	if !apierrors.IsConflict(err) || !errors.As(err, &statusErr) || statusErr.Status().Details == nil {
+		return fmt.Errorf("failed to apply %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	var conflicts []FieldConflict
+
+	for _, cause := range statusErr.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+
+		conflict := FieldConflict{Field: cause.Field, Message: cause.Message}
+		if match := conflictManagerPattern.FindStringSubmatch(cause.Message); match != nil {
+			conflict.Manager = match[1]
+		}
+
+		conflicts = append(conflicts, conflict)
+	}
+
+	if len(conflicts) == 0 {
+		return fmt.Errorf("failed to apply %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	return &FieldConflictError{
+		Kind:         objectKind(obj),
+		Namespace:    obj.GetNamespace(),
+		Name:         obj.GetName(),
+		FieldManager: r.fieldManager(),
+		Conflicts:    conflicts,
+		Err:          err,
+	}
+}
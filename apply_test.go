@@ -0,0 +1,189 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+func TestServerSideApply(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithServerSideApply("k8stest-test")).
+		WithDeployment("deployment-apply-1").
+		WithConfigMap("config-map-apply-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-apply-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(created.ManagedFields) == 0 || created.ManagedFields[0].Manager != "k8stest-test" {
+		t.Errorf("Expected the configmap to be managed by k8stest-test, got %v", created.ManagedFields)
+	}
+
+	resources.ConfigMaps[0].Data["new-key"] = "new-value"
+	resources.Deployments[0].Spec.Template.Spec.Containers[0].Image = "busybox:stable"
+
+	_, err = resources.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-apply-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied.UID != created.UID {
+		t.Errorf("Expected the configmap to be kept, got a new UID %s instead of %s", applied.UID, created.UID)
+	}
+
+	if applied.Data["new-key"] != "new-value" {
+		t.Errorf("Expected the applied data to be visible, got %v", applied.Data)
+	}
+
+	deployment, err := resources.TestClients.ClientSet.AppsV1().Deployments(resources.Namespace).Get(
+		context.Background(), "deployment-apply-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deployment.Generation != 2 {
+		t.Errorf("Expected the changed template to bump the generation to 2, got %d", deployment.Generation)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerSideApplyConflict(t *testing.T) {
+	other := NewWithClients(t, context.Background(), NewFakeTestClients(), WithServerSideApply("other-controller"))
+	other.WithConfigMap("config-map-apply-2").ConfigMaps[0].Data["key"] = "theirs"
+
+	_, err := other.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources := NewWithClients(t, context.Background(), other.TestClients, WithServerSideApply("k8stest-test"))
+	resources.WithConfigMap("config-map-apply-2").ConfigMaps[0].Data["key"] = "ours"
+
+	_, err = resources.Create()
+
+	var conflictErr *FieldConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a FieldConflictError, got %v", err)
+	}
+
+	if !apierrors.IsConflict(err) {
+		t.Errorf("Expected the error to wrap the conflict, got %v", err)
+	}
+
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Manager != "other-controller" ||
+		conflictErr.Conflicts[0].Field != ".data.key" {
+		t.Errorf("Expected a conflict on .data.key with other-controller, got %+v", conflictErr.Conflicts)
+	}
+
+	WithForceOwnership()(t, resources)
+
+	_, err = resources.Update(resources.ConfigMaps[0])
+	if err != nil {
+		t.Fatalf("Expected forced apply to succeed, got %v", err)
+	}
+
+	configMap, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(DefaultNamespace).Get(
+		context.Background(), "config-map-apply-2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.Data["key"] != "ours" {
+		t.Errorf("Expected forced apply to take over the field, got %v", configMap.Data)
+	}
+}
+
+func TestServerSideApplyKeepsControllerFields(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithServerSideApply("k8stest-test")).
+		WithDeployment("deployment-apply-3").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A controller that owns the revision annotation, like the deployment
+	// controller.
+	setRevision := func(revision string) {
+		t.Helper()
+
+		controller := &unstructured.Unstructured{}
+		controller.SetAPIVersion("apps/v1")
+		controller.SetKind("Deployment")
+		controller.SetNamespace(resources.Namespace)
+		controller.SetName("deployment-apply-3")
+		controller.SetAnnotations(map[string]string{revisionAnnotation: revision})
+
+		err := resources.TestClients.K8sClient.Apply(context.Background(),
+			client.ApplyConfigurationFromUnstructured(controller), client.FieldOwner("deployment-controller"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setRevision("1")
+
+	err = resources.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setRevision("2")
+
+	deployment := resources.Deployments[0]
+	deployment.Spec.Replicas = int32Ptr(2)
+
+	for range 2 {
+		_, err = resources.Update(deployment)
+		if err != nil {
+			t.Fatalf("Expected the re-apply to leave the fields of the controller alone, got %v", err)
+		}
+	}
+
+	applied, err := resources.TestClients.ClientSet.AppsV1().Deployments(resources.Namespace).Get(
+		context.Background(), "deployment-apply-3", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied.Annotations[revisionAnnotation] != "2" || *applied.Spec.Replicas != 2 {
+		t.Errorf("Expected revision 2 and 2 replicas, got %v and %d", applied.Annotations, *applied.Spec.Replicas)
+	}
+
+	for _, entry := range applied.ManagedFields {
+		if entry.Manager == "k8stest-test" && strings.Contains(string(entry.FieldsV1.Raw), revisionAnnotation) {
+			t.Errorf("Expected k8stest-test not to own the revision annotation, got %s", entry.FieldsV1.Raw)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CleanupPolicy controls what happens to the resources of a test once it ends.
+type CleanupPolicy int
+
+const (
+	// CleanupAlways deletes all resources when the test ends. This is the default.
+	CleanupAlways CleanupPolicy = iota
+	// CleanupOnSuccess deletes the resources only if the test passed and keeps
+	// them around for inspection when it failed.
+	CleanupOnSuccess
+	// CleanupNever leaves all resources in the cluster.
+	CleanupNever
+)
+
+// WithCleanupPolicy returns an Option that sets the CleanupPolicy used by the
+// teardown that Resources registers through t.Cleanup.
+func WithCleanupPolicy(policy CleanupPolicy) Option {
+	return func(_ *testing.T, r *Resources) {
+		r.CleanupPolicy = policy
+	}
+}
+
+// registerCleanup registers the teardown of all tracked resources with
+// t.Cleanup. It is called by Create before anything is created so that a
+// failing Create does not leak objects, and it registers at most once.
+func (r *Resources) registerCleanup() {
+	if r.t == nil || r.cleanupRegistered {
+		return
+	}
+
+	r.cleanupRegistered = true
+	cleanupCtx := context.WithoutCancel(*r.Ctx)
+
+	r.t.Cleanup(func() {
+		if !r.shouldCleanup(r.t) {
+			r.t.Logf("Keeping resources in namespace %s for inspection", r.namespace())
+
+			return
+		}
+
+		if err := r.teardown(cleanupCtx); err != nil {
+			r.t.Errorf("Failed to clean up resources: %v", err)
+		}
+	})
+}
+
+// testOutcome is the part of testing.TB shouldCleanup needs.
+type testOutcome interface {
+	Failed() bool
+}
+
+// shouldCleanup reports whether the CleanupPolicy allows deleting resources
+// for the outcome of test.
+func (r *Resources) shouldCleanup(test testOutcome) bool {
+	switch r.CleanupPolicy {
+	case CleanupNever:
+		return false
+	case CleanupOnSuccess:
+		return test == nil || !test.Failed()
+	case CleanupAlways:
+		return true
+	}
+
+	return true
+}
+
+// track remembers an object that was written through Update but is not part
+// of the typed slices, so that it is deleted together with the other resources.
+func (r *Resources) track(obj client.Object) {
+	if r.isTracked(obj) {
+		return
+	}
+
+	r.untracked = append(r.untracked, obj)
+}
+
+func (r *Resources) isTracked(obj client.Object) bool {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return containsNamed(r.Deployments, o.Name)
+	case *appsv1.StatefulSet:
+		return containsNamed(r.StatefulSets, o.Name)
+	case *appsv1.DaemonSet:
+		return containsNamed(r.DaemonSets, o.Name)
+	case *corev1.ConfigMap:
+		return containsNamed(r.ConfigMaps, o.Name)
+	case *corev1.Secret:
+		return containsNamed(r.Secrets, o.Name)
+	case *corev1.Service:
+		return containsNamed(r.Services, o.Name)
+	case *batchv1.Job:
+		return containsNamed(r.Jobs, o.Name)
+	case *batchv1.CronJob:
+		return containsNamed(r.CronJobs, o.Name)
+	}
+
+	for _, tracked := range r.Objects {
+		if tracked.Object == obj {
+			return true
+		}
+	}
+
+	for _, tracked := range r.untracked {
+		if tracked == obj {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsNamed[T client.Object](objects []T, name string) bool {
+	for _, obj := range objects {
+		if obj.GetName() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteUntracked deletes the objects remembered by track using the
+// controller-runtime client.
+func (r *Resources) deleteUntracked(ctx context.Context, opts metav1.DeleteOptions) error {
+	for _, obj := range r.untracked {
+		err := r.TestClients.K8sClient.Delete(ctx, obj, &client.DeleteOptions{Raw: &opts})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s: %w", obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testOutcomeStub is a test outcome that failed if it is true.
+type testOutcomeStub bool
+
+func (s testOutcomeStub) Failed() bool {
+	return bool(s)
+}
+
+func TestShouldCleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   CleanupPolicy
+		failed   bool
+		expected bool
+	}{
+		{
+			name:     "Always",
+			policy:   CleanupAlways,
+			expected: true,
+		},
+		{
+			name:     "On success with passing test",
+			policy:   CleanupOnSuccess,
+			expected: true,
+		},
+		{
+			name:     "On success with failed test",
+			policy:   CleanupOnSuccess,
+			failed:   true,
+			expected: false,
+		},
+		{
+			name:     "Never",
+			policy:   CleanupNever,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Resources{CleanupPolicy: tt.policy, t: t}
+
+			if r.shouldCleanup(testOutcomeStub(tt.failed)) != tt.expected {
+				t.Errorf("Expected shouldCleanup to be %v for policy %d", tt.expected, tt.policy)
+			}
+		})
+	}
+}
+
+func TestAutomaticCleanup(t *testing.T) {
+	cmName := "config-map-cleanup-1"
+	extraCmName := "config-map-cleanup-extra-1"
+
+	// The clients outlive the subtest, so that the cleanup can be checked.
+	clients := NewFakeTestClients()
+
+	var resources *Resources
+
+	t.Run("create", func(t *testing.T) {
+		var err error
+
+		resources, err = NewWithClients(t, context.Background(), clients).
+			WithConfigMap(cmName).
+			Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		extraCm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      extraCmName,
+				Namespace: resources.Namespace,
+			},
+		}
+
+		_, err = resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Create(
+			context.Background(), extraCm, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		extraCm.Data = map[string]string{"key": "value"}
+
+		_, err = resources.Update(extraCm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if resources == nil {
+		t.FailNow()
+	}
+
+	for _, name := range []string{cmName, extraCmName} {
+		_, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+			context.Background(), name, metav1.GetOptions{})
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("Expected configmap %s to be deleted after the test, got %v", name, err)
+		}
+	}
+}
+
+func TestCleanupNever(t *testing.T) {
+	cmName := "config-map-cleanup-never-1"
+
+	// The clients outlive the subtest, so that the cleanup can be checked.
+	clients := NewFakeTestClients()
+
+	var resources *Resources
+
+	t.Run("create", func(t *testing.T) {
+		var err error
+
+		resources, err = NewWithClients(t, context.Background(), clients, WithCleanupPolicy(CleanupNever)).
+			WithConfigMap(cmName).
+			Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if resources == nil {
+		t.FailNow()
+	}
+
+	_, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), cmName, metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Expected configmap %s to be kept after the test, got %v", cmName, err)
+	}
+
+	err = resources.Delete()
+	if err != nil {
+		t.Error(err)
+	}
+}
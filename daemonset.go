@@ -0,0 +1,72 @@
+package k8stest
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DaemonSet struct {
+	Resources
+}
+
+func (r *Resources) WithDaemonSet(name string) *DaemonSet {
+	r.DaemonSets = append(r.DaemonSets, &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "DaemonSet",
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace(),
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: createPodTemplateSpec(name),
+		},
+	})
+
+	r.ApplyOptions(r.DaemonSets[len(r.DaemonSets)-1])
+
+	return &DaemonSet{*r}
+}
+
+func (d *DaemonSet) WithSecret(name string) *DaemonSet {
+	resources := &d.Resources
+	resources.WithSecret(name)
+
+	daemonSet := d.DaemonSets[len(d.DaemonSets)-1]
+	attachSecretVolume(&daemonSet.Spec.Template.Spec, name)
+
+	return d
+}
+
+func (d *DaemonSet) WithConfigMap(name string) *DaemonSet {
+	resources := &d.Resources
+	resources.WithConfigMap(name)
+
+	daemonSet := d.DaemonSets[len(d.DaemonSets)-1]
+	attachConfigMapVolume(&daemonSet.Spec.Template.Spec, name)
+
+	return d
+}
+
+func (d *DaemonSet) And() *Resources {
+	return &d.Resources
+}
+
+// isDaemonSetReady reports whether a pod is ready on every node the DaemonSet
+// should run on and the latest rollout reached all of them.
+func isDaemonSetReady(daemonSet *appsv1.DaemonSet) bool {
+	status := daemonSet.Status
+
+	return status.ObservedGeneration >= daemonSet.Generation &&
+		status.NumberReady == status.DesiredNumberScheduled &&
+		status.UpdatedNumberScheduled == status.DesiredNumberScheduled
+}
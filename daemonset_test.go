@@ -0,0 +1,90 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDaemonSet(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithSimulator(WithNodes(3))).
+		WithDaemonSet("daemonset-1").
+		WithConfigMap("config-map-daemonset-1").
+		WithSecret("secret-daemonset-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "app=daemonset-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := map[string]bool{}
+	for _, pod := range pods.Items {
+		nodes[pod.Spec.NodeName] = true
+	}
+
+	if len(pods.Items) != 3 || len(nodes) != 3 {
+		t.Errorf("Expected one pod on each of the 3 nodes, got %d pods on %v", len(pods.Items), nodes)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDaemonSetRollout(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithSimulator(WithNodes(2))).
+		WithDaemonSet("daemonset-rollout-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The status written by the simulator changed the resourceVersion.
+	err = resources.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	daemonSet := resources.DaemonSets[0]
+	daemonSet.Spec.Template.Spec.Containers[0].Image = "busybox:stable"
+
+	_, err = resources.Update(daemonSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "app=daemonset-rollout-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil && pod.Spec.Containers[0].Image != "busybox:stable" {
+			t.Errorf("Expected pod %s to run the new image, got %s", pod.Name, pod.Spec.Containers[0].Image)
+		}
+	}
+}
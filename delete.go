@@ -0,0 +1,335 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StuckObject describes an object that still existed when DeleteAndWait timed out.
+type StuckObject struct {
+	Kind       string
+	Name       string
+	Finalizers []string
+}
+
+func (s StuckObject) String() string {
+	if len(s.Finalizers) == 0 {
+		return s.Kind + " " + s.Name
+	}
+
+	return fmt.Sprintf("%s %s (finalizers: %s)", s.Kind, s.Name, strings.Join(s.Finalizers, ", "))
+}
+
+// DeletionTimeoutError is returned by DeleteAndWait when not all objects were
+// gone before the timeout expired.
+type DeletionTimeoutError struct {
+	Stuck []StuckObject
+	Err   error
+}
+
+func (e *DeletionTimeoutError) Error() string {
+	stuck := make([]string, 0, len(e.Stuck))
+	for _, s := range e.Stuck {
+		stuck = append(stuck, s.String())
+	}
+
+	return fmt.Sprintf("failed to wait for deletion of %s: %v", strings.Join(stuck, "; "), e.Err)
+}
+
+func (e *DeletionTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// WithWaitForDeletion returns an Option that makes Delete, Create and the
+// automatic cleanup block until all resources are gone.
+func WithWaitForDeletion() Option {
+	return func(_ *testing.T, r *Resources) {
+		r.WaitForDeletion = true
+	}
+}
+
+// DeleteAndWait deletes all tracked resources with foreground propagation and
+// blocks until every Deployment, StatefulSet, DaemonSet, Job and CronJob with its
+// ReplicaSets, Jobs and pods, and every Service, ConfigMap and Secret, is gone. When the timeout expires a
+// *DeletionTimeoutError lists the objects that are still present. Other errors,
+// e.g. of the API server, are returned as they are.
+func (r *Resources) DeleteAndWait(timeout ...time.Duration) error {
+	applicableTimeout := r.Timeout
+
+	if len(timeout) > 0 {
+		applicableTimeout = timeout[0]
+	}
+
+	return r.deleteAndWait(*r.Ctx, applicableTimeout)
+}
+
+func (r *Resources) deleteAndWait(ctx context.Context, timeout time.Duration) error {
+	foreground := metav1.DeletePropagationForeground
+
+	err := r.delete(ctx, metav1.DeleteOptions{PropagationPolicy: &foreground})
+	if err != nil {
+		return err
+	}
+
+	var stuck []StuckObject
+
+	err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, timeout, true,
+		func(ctx context.Context) (bool, error) {
+			remaining, err := r.remainingObjects(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			stuck = remaining
+
+			return len(stuck) == 0, nil
+		})
+	if err != nil && !wait.Interrupted(err) {
+		return fmt.Errorf("failed to wait for deletion: %w", err)
+	}
+
+	if err != nil {
+		return &DeletionTimeoutError{Stuck: stuck, Err: err}
+	}
+
+	return nil
+}
+
+// remainingObjects returns all tracked objects and their dependents that still
+// exist in the cluster.
+//
+//nolint:cyclop // one branch per tracked kind
+func (r *Resources) remainingObjects(ctx context.Context) ([]StuckObject, error) {
+	var remaining []StuckObject
+
+	apps := r.TestClients.ClientSet.AppsV1()
+	core := r.TestClients.ClientSet.CoreV1()
+
+	for _, cronJob := range r.CronJobs {
+		cj, err := r.TestClients.ClientSet.BatchV1().CronJobs(r.namespace()).Get(ctx, cronJob.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "cronjob", cj, err); err != nil {
+			return nil, err
+		}
+
+		if remaining, err = r.appendRemainingCronJobJobs(ctx, remaining, cronJob.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, job := range r.Jobs {
+		j, err := r.TestClients.ClientSet.BatchV1().Jobs(r.namespace()).Get(ctx, job.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "job", j, err); err != nil {
+			return nil, err
+		}
+
+		if remaining, err = r.appendRemainingPods(ctx, remaining, jobPodSelector(job.Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, statefulSet := range r.StatefulSets {
+		sts, err := apps.StatefulSets(r.namespace()).Get(ctx, statefulSet.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "statefulset", sts, err); err != nil {
+			return nil, err
+		}
+
+		if remaining, err = r.appendRemainingPods(ctx, remaining, statefulSet.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, daemonSet := range r.DaemonSets {
+		ds, err := apps.DaemonSets(r.namespace()).Get(ctx, daemonSet.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "daemonset", ds, err); err != nil {
+			return nil, err
+		}
+
+		if remaining, err = r.appendRemainingPods(ctx, remaining, daemonSet.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, deployment := range r.Deployments {
+		dep, err := apps.Deployments(r.namespace()).Get(ctx, deployment.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "deployment", dep, err); err != nil {
+			return nil, err
+		}
+
+		if remaining, err = r.appendRemainingReplicaSets(ctx, remaining, deployment.Spec.Selector); err != nil {
+			return nil, err
+		}
+
+		if remaining, err = r.appendRemainingPods(ctx, remaining, deployment.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+
+	remaining, err := r.appendRemainingObjects(ctx, remaining)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range r.Services {
+		svc, err := core.Services(r.namespace()).Get(ctx, service.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "service", svc, err); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, secret := range r.Secrets {
+		sec, err := core.Secrets(r.namespace()).Get(ctx, secret.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "secret", sec, err); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, configMap := range r.ConfigMaps {
+		cm, err := core.ConfigMaps(r.namespace()).Get(ctx, configMap.Name, metav1.GetOptions{})
+		if remaining, err = appendIfExists(remaining, "configmap", cm, err); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, obj := range r.untracked {
+		current, ok := obj.DeepCopyObject().(client.Object)
+		if !ok {
+			continue
+		}
+
+		err := r.TestClients.K8sClient.Get(ctx, client.ObjectKeyFromObject(obj), current)
+		if remaining, err = appendIfExists(remaining, objectKind(obj), current, err); err != nil {
+			return nil, err
+		}
+	}
+
+	return remaining, nil
+}
+
+// appendRemainingCronJobJobs appends the Jobs created by the CronJob with the
+// given name and their pods.
+func (r *Resources) appendRemainingCronJobJobs(ctx context.Context, remaining []StuckObject,
+	cronJobName string,
+) ([]StuckObject, error) {
+	jobs, err := r.TestClients.ClientSet.BatchV1().Jobs(r.namespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	for i := range jobs.Items {
+		ref := metav1.GetControllerOf(&jobs.Items[i])
+		if ref == nil || ref.Kind != "CronJob" || ref.Name != cronJobName {
+			continue
+		}
+
+		remaining = append(remaining, stuckObject("job", &jobs.Items[i]))
+
+		if remaining, err = r.appendRemainingPods(ctx, remaining, jobPodSelector(jobs.Items[i].Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return remaining, nil
+}
+
+func (r *Resources) appendRemainingReplicaSets(ctx context.Context, remaining []StuckObject,
+	selector *metav1.LabelSelector,
+) ([]StuckObject, error) {
+	listOptions, ok := selectorListOptions(selector)
+	if !ok {
+		return remaining, nil
+	}
+
+	replicaSets, err := r.TestClients.ClientSet.AppsV1().ReplicaSets(r.namespace()).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+
+	for i := range replicaSets.Items {
+		remaining = append(remaining, stuckObject("replicaset", &replicaSets.Items[i]))
+	}
+
+	return remaining, nil
+}
+
+func (r *Resources) appendRemainingPods(ctx context.Context, remaining []StuckObject,
+	selector *metav1.LabelSelector,
+) ([]StuckObject, error) {
+	listOptions, ok := selectorListOptions(selector)
+	if !ok {
+		return remaining, nil
+	}
+
+	pods, err := r.TestClients.ClientSet.CoreV1().Pods(r.namespace()).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	for i := range pods.Items {
+		remaining = append(remaining, stuckObject("pod", &pods.Items[i]))
+	}
+
+	return remaining, nil
+}
+
+func appendIfExists(remaining []StuckObject, kind string, obj metav1.Object, err error) ([]StuckObject, error) {
+	if apierrors.IsNotFound(err) {
+		return remaining, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", kind, err)
+	}
+
+	return append(remaining, stuckObject(kind, obj)), nil
+}
+
+func stuckObject(kind string, obj metav1.Object) StuckObject {
+	return StuckObject{
+		Kind:       kind,
+		Name:       obj.GetName(),
+		Finalizers: obj.GetFinalizers(),
+	}
+}
+
+// jobPodSelector selects the pods of the Job with the given name by the label
+// the Job controller puts on them, which outlives the Job itself.
+func jobPodSelector(jobName string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			batchv1.JobNameLabel: jobName,
+		},
+	}
+}
+
+func selectorListOptions(selector *metav1.LabelSelector) (metav1.ListOptions, bool) {
+	if selector == nil {
+		return metav1.ListOptions{}, false
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil || labelSelector.Empty() {
+		return metav1.ListOptions{}, false
+	}
+
+	return metav1.ListOptions{LabelSelector: labelSelector.String()}, true
+}
+
+// objectKind returns the lower-cased kind of obj for messages, falling back to
+// its Go type when TypeMeta is not populated.
+func objectKind(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return strings.ToLower(kind)
+	}
+
+	return fmt.Sprintf("%T", obj)
+}
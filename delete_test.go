@@ -0,0 +1,108 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestDeletionTimeoutError(t *testing.T) {
+	err := &DeletionTimeoutError{
+		Stuck: []StuckObject{
+			{Kind: "deployment", Name: "web", Finalizers: []string{"foregroundDeletion"}},
+			{Kind: "pod", Name: "web-abc"},
+		},
+		Err: context.DeadlineExceeded,
+	}
+
+	expected := "failed to wait for deletion of deployment web (finalizers: foregroundDeletion); " +
+		"pod web-abc: context deadline exceeded"
+	if err.Error() != expected {
+		t.Errorf("Expected error %q, got %q", expected, err.Error())
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected DeletionTimeoutError to unwrap to context.DeadlineExceeded")
+	}
+}
+
+func TestDeleteAndWaitReturnsAPIErrors(t *testing.T) {
+	testClients := NewFakeTestClients()
+
+	clientSet, ok := testClients.ClientSet.(*kubefake.Clientset)
+	if !ok {
+		t.Fatal("Expected a fake clientset")
+	}
+
+	resources, err := NewWithClients(t, context.Background(), testClients, WithTestNamespace()).
+		WithConfigMap("config-map-delete-wait-2").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errUnavailable := apierrors.NewServiceUnavailable("etcd is down")
+
+	clientSet.PrependReactor("get", "configmaps", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errUnavailable
+	})
+
+	err = resources.DeleteAndWait(time.Second)
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("Expected the error of the API server, got %v", err)
+	}
+
+	var timeoutErr *DeletionTimeoutError
+	if errors.As(err, &timeoutErr) {
+		t.Errorf("Expected no DeletionTimeoutError for an API error, got %v", err)
+	}
+}
+
+func TestDeleteAndWait(t *testing.T) {
+	resources, err := New(t, context.Background(), WithWaitForDeletion()).
+		WithResourceOption(ZeroTerminationGracePeriodOption()).
+		WithDeployment("deployment-delete-wait-1").
+		WithConfigMap("config-map-delete-wait-1").
+		And().
+		WithStatefulSet("statefulset-delete-wait-1").
+		WithSecret("secret-delete-wait-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = resources.DeleteAndWait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+		context.Background(),
+		metav1.ListOptions{LabelSelector: "app in (deployment-delete-wait-1,statefulset-delete-wait-1)"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods.Items) != 0 {
+		t.Errorf("Expected all pods to be gone, found %d", len(pods.Items))
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-delete-wait-1", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected configmap to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// diagnosticsTimeout limits how long collecting diagnostics for a timed out
+	// wait may take, as the deadline of the wait itself has already passed.
+	diagnosticsTimeout = 2 * time.Second
+	// diagnosticsEvents is the number of most recent events kept per resource.
+	diagnosticsEvents = 10
+	// diagnosticsLogLines is the number of log lines kept per container.
+	diagnosticsLogLines int64 = 10
+)
+
+// WaitTimeoutError is returned by Wait and WaitForJob when not all resources
+// were ready before the timeout expired. Its message contains a report of the
+// diagnostics collected for each resource that was not ready.
+type WaitTimeoutError struct {
+	Resources []ResourceDiagnostics
+	Err       error
+}
+
+// ResourceDiagnostics describes the state of a resource that was not ready
+// when a wait timed out. Err is set when not all diagnostics could be collected.
+type ResourceDiagnostics struct {
+	Kind   string
+	Name   string
+	Status string
+	Pods   []PodDiagnostics
+	Events []EventDiagnostics
+	Err    error
+}
+
+// PodDiagnostics describes a pod of a resource that was not ready. Reason and
+// Message explain why the pod is not running, e.g. because it is Unschedulable.
+type PodDiagnostics struct {
+	Name       string
+	Phase      corev1.PodPhase
+	Reason     string
+	Message    string
+	Containers []ContainerDiagnostics
+}
+
+// ContainerDiagnostics describes a container of a pod. State is "waiting",
+// "running" or "terminated", Reason and Message are those of the waiting or
+// terminated state, e.g. ErrImagePull or CrashLoopBackOff. Logs holds the last
+// lines the container wrote, or wrote before its last restart while it waits.
+type ContainerDiagnostics struct {
+	Name         string
+	State        string
+	Reason       string
+	Message      string
+	ExitCode     int32
+	RestartCount int32
+	Logs         []string
+}
+
+// EventDiagnostics is an event of a resource or one of its pods.
+type EventDiagnostics struct {
+	Object  string
+	Type    string
+	Reason  string
+	Message string
+	Count   int32
+}
+
+func (e *WaitTimeoutError) Error() string {
+	pending := make([]string, 0, len(e.Resources))
+	for _, resource := range e.Resources {
+		pending = append(pending, resource.Kind+" "+resource.Name)
+	}
+
+	return fmt.Sprintf("failed to wait for %s: %v\n%s", strings.Join(pending, "; "), e.Err, e.Report())
+}
+
+func (e *WaitTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Report formats the diagnostics of all resources that were not ready.
+func (e *WaitTimeoutError) Report() string {
+	var report strings.Builder
+
+	for _, resource := range e.Resources {
+		resource.writeReport(&report)
+	}
+
+	return report.String()
+}
+
+func (d ResourceDiagnostics) writeReport(w *strings.Builder) {
+	fmt.Fprintf(w, "%s %s:\n", d.Kind, d.Name)
+
+	if d.Status != "" {
+		w.WriteString("  status:\n")
+		writeIndented(w, "    ", d.Status)
+	}
+
+	if len(d.Pods) > 0 {
+		w.WriteString("  pods:\n")
+	}
+
+	for _, pod := range d.Pods {
+		pod.writeReport(w)
+	}
+
+	if len(d.Events) > 0 {
+		w.WriteString("  events:\n")
+	}
+
+	for _, event := range d.Events {
+		fmt.Fprintf(w, "    %s %s %s: %s (x%d)\n", event.Type, event.Reason, event.Object, event.Message, event.Count)
+	}
+
+	if d.Err != nil {
+		fmt.Fprintf(w, "  incomplete diagnostics: %v\n", d.Err)
+	}
+}
+
+func (d PodDiagnostics) writeReport(w *strings.Builder) {
+	fmt.Fprintf(w, "    %s: %s", d.Name, d.Phase)
+
+	if d.Reason != "" {
+		fmt.Fprintf(w, " (%s: %s)", d.Reason, d.Message)
+	}
+
+	w.WriteString("\n")
+
+	for _, container := range d.Containers {
+		fmt.Fprintf(w, "      container %s: %s", container.Name, container.State)
+
+		if container.Reason != "" {
+			fmt.Fprintf(w, " %s", container.Reason)
+		}
+
+		if container.State == "terminated" {
+			fmt.Fprintf(w, " (exit code %d)", container.ExitCode)
+		}
+
+		if container.Message != "" {
+			fmt.Fprintf(w, ": %s", container.Message)
+		}
+
+		fmt.Fprintf(w, ", restarts: %d\n", container.RestartCount)
+
+		for _, line := range container.Logs {
+			fmt.Fprintf(w, "        | %s\n", line)
+		}
+	}
+}
+
+func writeIndented(w *strings.Builder, indent, text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		w.WriteString(indent + line + "\n")
+	}
+}
+
+// diagnose collects the diagnostics of targets concurrently. It uses its own
+// deadline, as the one of the wait has usually passed when it is called.
+func (r *Resources) diagnose(targets []waitTarget) []ResourceDiagnostics {
+	ctx, cancel := context.WithTimeout(*r.Ctx, diagnosticsTimeout)
+	defer cancel()
+
+	diagnostics := make([]ResourceDiagnostics, len(targets))
+
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			diagnostics[i] = r.diagnoseTarget(ctx, target)
+		}()
+	}
+
+	wg.Wait()
+
+	return diagnostics
+}
+
+// diagnoseTarget collects the status, pods and events of target. Collecting
+// diagnostics is best effort, failures are recorded in the result.
+func (r *Resources) diagnoseTarget(ctx context.Context, target waitTarget) ResourceDiagnostics {
+	diagnostics := ResourceDiagnostics{Kind: target.kind, Name: target.name}
+
+	var errs []error
+
+	obj, err := target.get(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get %s: %w", target.kind, err))
+	} else if diagnostics.Status, err = statusOf(obj); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Events are matched by the name of the object they refer to.
+	involved := map[string]string{target.name: target.kind}
+
+	pods, err := r.podsOf(ctx, obj)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for i := range pods {
+		diagnostics.Pods = append(diagnostics.Pods, r.diagnosePod(ctx, &pods[i]))
+		involved[pods[i].Name] = "pod"
+	}
+
+	if diagnostics.Events, err = r.eventsOf(ctx, target.namespace, involved); err != nil {
+		errs = append(errs, err)
+	}
+
+	diagnostics.Err = errors.Join(errs...)
+
+	return diagnostics
+}
+
+// statusOf returns the status of obj as YAML, or an empty string if it has none.
+func statusOf(obj runtime.Object) (string, error) {
+	var content map[string]any
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content = u.Object
+	} else {
+		var err error
+
+		if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return "", fmt.Errorf("failed to convert object: %w", err)
+		}
+	}
+
+	status, ok := content["status"].(map[string]any)
+	if !ok || len(status) == 0 {
+		return "", nil
+	}
+
+	out, err := yaml.Marshal(status)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal status: %w", err)
+	}
+
+	return string(out), nil
+}
+
+// podsOf returns the pods of obj if it is a workload, or the pods in obj if
+// it is a list of pods, as waited for by WaitForPodPhase.
+func (r *Resources) podsOf(ctx context.Context, obj runtime.Object) ([]corev1.Pod, error) {
+	if list, ok := obj.(*corev1.PodList); ok {
+		return list.Items, nil
+	}
+
+	workload, ok := obj.(client.Object)
+	if !ok || podSelectorOf(obj) == nil {
+		return nil, nil
+	}
+
+	return r.listPods(ctx, workload)
+}
+
+// podSelectorOf returns the selector of the pods obj runs, or nil if obj is not
+// a workload.
+func podSelectorOf(obj runtime.Object) *metav1.LabelSelector {
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		return workload.Spec.Selector
+	case *appsv1.StatefulSet:
+		return workload.Spec.Selector
+	case *appsv1.DaemonSet:
+		return workload.Spec.Selector
+	case *batchv1.Job:
+		return workload.Spec.Selector
+	default:
+		return nil
+	}
+}
+
+func (r *Resources) diagnosePod(ctx context.Context, pod *corev1.Pod) PodDiagnostics {
+	diagnostics := PodDiagnostics{
+		Name:    pod.Name,
+		Phase:   pod.Status.Phase,
+		Reason:  pod.Status.Reason,
+		Message: pod.Status.Message,
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			diagnostics.Reason, diagnostics.Message = condition.Reason, condition.Message
+		}
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		diagnostics.Containers = append(diagnostics.Containers, r.diagnoseContainer(ctx, pod, status))
+	}
+
+	return diagnostics
+}
+
+func (r *Resources) diagnoseContainer(ctx context.Context, pod *corev1.Pod,
+	status corev1.ContainerStatus,
+) ContainerDiagnostics {
+	diagnostics := ContainerDiagnostics{Name: status.Name, RestartCount: status.RestartCount}
+
+	switch {
+	case status.State.Waiting != nil:
+		diagnostics.State = "waiting"
+		diagnostics.Reason = status.State.Waiting.Reason
+		diagnostics.Message = status.State.Waiting.Message
+	case status.State.Terminated != nil:
+		diagnostics.State = "terminated"
+		diagnostics.Reason = status.State.Terminated.Reason
+		diagnostics.Message = status.State.Terminated.Message
+		diagnostics.ExitCode = status.State.Terminated.ExitCode
+	case status.State.Running != nil:
+		diagnostics.State = "running"
+	}
+
+	// A waiting container has no logs of its own, but those of its previous
+	// run, if there was one, usually tell why it crashed.
+	previous := status.State.Waiting != nil
+	if previous && status.RestartCount == 0 {
+		return diagnostics
+	}
+
+	tailLines := diagnosticsLogLines
+
+	logs, err := r.TestClients.ClientSet.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: status.Name,
+		TailLines: &tailLines,
+		Previous:  previous,
+	}).DoRaw(ctx)
+	if err == nil && len(logs) > 0 {
+		diagnostics.Logs = strings.Split(strings.TrimRight(string(logs), "\n"), "\n")
+	}
+
+	return diagnostics
+}
+
+// eventsOf returns the most recent events of the objects in involved, which
+// maps their names to their kinds.
+func (r *Resources) eventsOf(ctx context.Context, namespace string,
+	involved map[string]string,
+) ([]EventDiagnostics, error) {
+	events, err := r.TestClients.ClientSet.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	var matching []corev1.Event
+
+	for _, event := range events.Items {
+		kind, ok := involved[event.InvolvedObject.Name]
+		if ok && strings.EqualFold(kind, event.InvolvedObject.Kind) {
+			matching = append(matching, event)
+		}
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return eventTime(matching[i]).Before(eventTime(matching[j]))
+	})
+
+	if len(matching) > diagnosticsEvents {
+		matching = matching[len(matching)-diagnosticsEvents:]
+	}
+
+	diagnostics := make([]EventDiagnostics, 0, len(matching))
+	for _, event := range matching {
+		diagnostics = append(diagnostics, EventDiagnostics{
+			Object:  strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name,
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Message: event.Message,
+			Count:   event.Count,
+		})
+	}
+
+	return diagnostics, nil
+}
+
+// eventTime returns when event last occurred. Depending on the reporting
+// component, only some of the timestamps are set.
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
@@ -0,0 +1,135 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWaitTimeoutDiagnostics(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithSimulator(WithImageFailure("busybox:latest", "ErrImagePull"))).
+		WithDeployment("deployment-diagnostics-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().Events(resources.Namespace).Create(context.Background(),
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployment-diagnostics-1.1"},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "Deployment",
+				Name: "deployment-diagnostics-1",
+			},
+			Type:    corev1.EventTypeNormal,
+			Reason:  "ScalingReplicaSet",
+			Message: "Scaled up replica set to 1",
+			Count:   1,
+		}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(500 * time.Millisecond)
+
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected a WaitTimeoutError, got %v", err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected WaitTimeoutError to unwrap to context.DeadlineExceeded")
+	}
+
+	if len(timeoutErr.Resources) != 1 {
+		t.Fatalf("Expected diagnostics for one resource, got %d", len(timeoutErr.Resources))
+	}
+
+	diagnostics := timeoutErr.Resources[0]
+
+	if diagnostics.Err != nil {
+		t.Errorf("Expected complete diagnostics, got %v", diagnostics.Err)
+	}
+
+	if !strings.Contains(diagnostics.Status, "replicas: 1") {
+		t.Errorf("Expected the status of the deployment, got %q", diagnostics.Status)
+	}
+
+	if len(diagnostics.Pods) != 1 || len(diagnostics.Pods[0].Containers) != 1 ||
+		diagnostics.Pods[0].Containers[0].Reason != "ErrImagePull" {
+		t.Fatalf("Expected one pod whose container cannot pull its image, got %+v", diagnostics.Pods)
+	}
+
+	if len(diagnostics.Events) != 1 || diagnostics.Events[0].Reason != "ScalingReplicaSet" {
+		t.Errorf("Expected the event of the deployment, got %+v", diagnostics.Events)
+	}
+
+	for _, expected := range []string{
+		"failed to wait for deployment deployment-diagnostics-1: context deadline exceeded",
+		"container noop-container: waiting ErrImagePull",
+		"Normal ScalingReplicaSet deployment/deployment-diagnostics-1: Scaled up replica set to 1 (x1)",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected the error to contain %q, got:\n%s", expected, err.Error())
+		}
+	}
+}
+
+func TestWaitTimeoutErrorReport(t *testing.T) {
+	err := &WaitTimeoutError{
+		Resources: []ResourceDiagnostics{{
+			Kind:   "statefulset",
+			Name:   "web",
+			Status: "readyReplicas: 0\nreplicas: 1\n",
+			Pods: []PodDiagnostics{{
+				Name:    "web-0",
+				Phase:   corev1.PodPending,
+				Reason:  "Unschedulable",
+				Message: "0/1 nodes are available",
+			}, {
+				Name:  "web-1",
+				Phase: corev1.PodRunning,
+				Containers: []ContainerDiagnostics{{
+					Name:         "app",
+					State:        "waiting",
+					Reason:       "CrashLoopBackOff",
+					Message:      "back-off 10s restarting failed container",
+					RestartCount: 2,
+					Logs:         []string{"starting", "panic: boom"},
+				}},
+			}},
+			Events: []EventDiagnostics{{
+				Object:  "pod/web-0",
+				Type:    corev1.EventTypeWarning,
+				Reason:  "FailedScheduling",
+				Message: "0/1 nodes are available",
+				Count:   3,
+			}},
+		}},
+		Err: context.DeadlineExceeded,
+	}
+
+	expected := `statefulset web:
+  status:
+    readyReplicas: 0
+    replicas: 1
+  pods:
+    web-0: Pending (Unschedulable: 0/1 nodes are available)
+    web-1: Running
+      container app: waiting CrashLoopBackOff: back-off 10s restarting failed container, restarts: 2
+        | starting
+        | panic: boom
+  events:
+    Warning FailedScheduling pod/web-0: 0/1 nodes are available (x3)
+`
+	if report := err.Report(); report != expected {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", expected, report)
+	}
+}
@@ -0,0 +1,112 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	k8sinternal "github.com/tom1299/k8stest/internal"
+)
+
+// EnvTestOption configures the envtest environment started by StartEnvTest.
+type EnvTestOption func(environment *envtest.Environment)
+
+// EnvTest is a local control plane (kube-apiserver and etcd) started through
+// controller-runtime's envtest. It runs no controllers and no kubelet, combine
+// it with WithSimulator to let Deployments and StatefulSets become ready.
+type EnvTest struct {
+	Environment *envtest.Environment
+	TestClients *TestClients
+}
+
+// sharedEnvTest is the control plane started by RunWithEnvTest for the tests
+// of a package.
+var sharedEnvTest *EnvTest
+
+// WithCRDDirectory returns an EnvTestOption that installs all CRDs found in the
+// given directories. A missing directory is an error.
+func WithCRDDirectory(paths ...string) EnvTestOption {
+	return func(environment *envtest.Environment) {
+		environment.CRDDirectoryPaths = append(environment.CRDDirectoryPaths, paths...)
+		environment.ErrorIfCRDPathMissing = true
+	}
+}
+
+// WithBinaryAssetsDirectory returns an EnvTestOption that sets the directory
+// containing the etcd and kube-apiserver binaries. Without it envtest uses
+// KUBEBUILDER_ASSETS or /usr/local/kubebuilder/bin.
+func WithBinaryAssetsDirectory(dir string) EnvTestOption {
+	return func(environment *envtest.Environment) {
+		environment.BinaryAssetsDirectory = dir
+	}
+}
+
+// StartEnvTest starts a local kube-apiserver and etcd using locally installed
+// binaries and returns the running EnvTest with ready-to-use TestClients.
+// Call Stop when done.
+func StartEnvTest(opts ...EnvTestOption) (*EnvTest, error) {
+	environment := &envtest.Environment{}
+
+	for _, opt := range opts {
+		opt(environment)
+	}
+
+	clientSet, k8sClient, err := k8sinternal.BuildEnvTestClients(environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start envtest: %w", err)
+	}
+
+	return &EnvTest{
+		Environment: environment,
+		TestClients: NewTestClients(clientSet, k8sClient),
+	}, nil
+}
+
+// Stop shuts the control plane down.
+func (e *EnvTest) Stop() error {
+	return e.Environment.Stop()
+}
+
+// RunWithEnvTest starts an envtest control plane, runs the tests of the
+// package and stops the control plane again. It is meant to be called from
+// TestMain, after which NewEnvTest gives every test access to the shared
+// control plane:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(k8stest.RunWithEnvTest(m, k8stest.WithCRDDirectory("testdata/crds")))
+//	}
+func RunWithEnvTest(m *testing.M, opts ...EnvTestOption) int {
+	env, err := StartEnvTest(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start envtest: %v\n", err)
+
+		return 1
+	}
+
+	sharedEnvTest = env
+	code := m.Run()
+	sharedEnvTest = nil
+
+	if err := env.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to stop envtest: %v\n", err)
+
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// NewEnvTest works like New but uses the control plane started by
+// RunWithEnvTest instead of the current kubeconfig.
+func NewEnvTest(t *testing.T, ctx context.Context, opts ...Option) *Resources {
+	if sharedEnvTest == nil {
+		t.Fatal("NewEnvTest requires RunWithEnvTest to be called from TestMain")
+	}
+
+	return NewWithClients(t, ctx, sharedEnvTest.TestClients, opts...)
+}
@@ -0,0 +1,89 @@
+package k8stest
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func TestEnvTestOptions(t *testing.T) {
+	environment := &envtest.Environment{}
+
+	WithCRDDirectory("testdata/crds")(environment)
+	WithBinaryAssetsDirectory("/opt/kubebuilder/bin")(environment)
+
+	if len(environment.CRDDirectoryPaths) != 1 || environment.CRDDirectoryPaths[0] != "testdata/crds" {
+		t.Errorf("Expected CRD directory testdata/crds, got %v", environment.CRDDirectoryPaths)
+	}
+
+	if !environment.ErrorIfCRDPathMissing {
+		t.Error("Expected a missing CRD directory to be an error")
+	}
+
+	if environment.BinaryAssetsDirectory != "/opt/kubebuilder/bin" {
+		t.Errorf("Expected binary assets directory /opt/kubebuilder/bin, got %q", environment.BinaryAssetsDirectory)
+	}
+}
+
+func TestStartEnvTest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	env, err := StartEnvTest(WithCRDDirectory("testdata/crds"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// NewEnvTest uses the control plane started by RunWithEnvTest.
+	sharedEnvTest = env
+
+	t.Cleanup(func() {
+		sharedEnvTest = nil
+	})
+
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	widget.SetName("widget-envtest-1")
+	widget.Object["spec"] = map[string]any{"size": int64(3)}
+
+	resources, err := NewEnvTest(t, context.Background(), WithTestNamespace()).
+		WithConfigMap("config-map-envtest-1").
+		WithUnstructured(widget).
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-envtest-1", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Expected the configmap to be created: %v", err)
+	}
+
+	created := &unstructured.Unstructured{}
+	created.SetGroupVersionKind(widget.GroupVersionKind())
+
+	err = resources.TestClients.K8sClient.Get(context.Background(), client.ObjectKeyFromObject(widget), created)
+	if err != nil {
+		t.Errorf("Expected the widget of the CRD from testdata/crds to be created: %v", err)
+	}
+
+	err = resources.DeleteAndWait(10 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
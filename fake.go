@@ -0,0 +1,525 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// errObjectModified is the cause of the Conflict errors of versionedTracker.
+var errObjectModified = errors.New("object was modified")
+
+// generationTracker bumps metadata.generation whenever the spec of an object
+// changes, like the API server does, so that observedGeneration can be relied
+// upon in fake mode.
+type generationTracker struct {
+	clienttesting.ObjectTracker
+}
+
+// NewFakeTestClients creates TestClients backed by in-memory fakes instead of a
+// cluster. The fake clientset and the fake controller-runtime client share one
+// object tracker, so objects written through one are visible through the other.
+// The given objects are added to the tracker up front.
+func NewFakeTestClients(objects ...runtime.Object) *TestClients {
+	clientSet := kubefake.NewClientset(objects...)
+	tracker := generationTracker{clientSet.Tracker()}
+	versioned := versionedTracker{tracker}
+	// All writes are serialized, each together with the read it is based on,
+	// so that a patch, which reads the object and writes it back, cannot
+	// revert a concurrent write of the other client.
+	writes := &sync.Mutex{}
+	objectReaction := serialized(writes, clienttesting.ObjectReaction(versioned))
+
+	clientSet.PrependReactor("create", "*", serialized(writes, defaultingReactor(versioned)))
+	clientSet.PrependReactor("update", "*", objectReaction)
+	clientSet.PrependReactor("patch", "*", objectReaction)
+	clientSet.PrependReactor("delete", "*", objectReaction)
+
+	k8sClient := crfake.NewClientBuilder().
+		WithScheme(SetupScheme()).
+		WithObjectTracker(tracker).
+		Build()
+
+	return NewTestClients(clientSet, interceptor.NewClient(k8sClient, serializedWrites(writes)))
+}
+
+// NewFake works like New but runs against NewFakeTestClients and starts a
+// Simulator, so the whole Create/Wait/Delete flow works without a cluster.
+// Pass WithSimulator to configure the Simulator.
+func NewFake(t *testing.T, ctx context.Context, opts ...Option) *Resources {
+	r := NewWithClients(t, ctx, NewFakeTestClients(), opts...)
+
+	if r.Simulator == nil {
+		WithSimulator()(t, r)
+	}
+
+	return r
+}
+
+// defaultingReactor fills in the fields an API server sets on create, which
+// the fake clientset leaves empty. It works on a copy so that the caller's
+// object is not modified, just like with a real API server.
+func defaultingReactor(tracker clienttesting.ObjectTracker) clienttesting.ReactionFunc {
+	objectReaction := clienttesting.ObjectReaction(tracker)
+
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		createAction, ok := action.(clienttesting.CreateActionImpl)
+		if !ok || createAction.GetSubresource() != "" {
+			return false, nil, nil
+		}
+
+		obj := createAction.GetObject().DeepCopyObject()
+		setServerDefaults(obj)
+		createAction.Object = obj
+
+		return objectReaction(createAction)
+	}
+}
+
+// serialized returns a ReactionFunc that runs reaction while holding mu.
+func serialized(mu *sync.Mutex, reaction clienttesting.ReactionFunc) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return reaction(action)
+	}
+}
+
+// serializedWrites returns interceptor functions that hold mu during every
+// write of the controller-runtime client, so that its read-modify-write cycles
+// do not interleave with the reactions of the clientset.
+func serializedWrites(mu *sync.Mutex) interceptor.Funcs {
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.Create(ctx, obj, opts...)
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.Update(ctx, obj, opts...)
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+			opts ...client.PatchOption,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+		Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration,
+			opts ...client.ApplyOption,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.Apply(ctx, obj, opts...)
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.Delete(ctx, obj, opts...)
+		},
+		DeleteAllOf: func(ctx context.Context, c client.WithWatch, obj client.Object,
+			opts ...client.DeleteAllOfOption,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.DeleteAllOf(ctx, obj, opts...)
+		},
+		SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+			subResource client.Object, opts ...client.SubResourceCreateOption,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+			opts ...client.SubResourceUpdateOption,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+		},
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+			patch client.Patch, opts ...client.SubResourcePatchOption,
+		) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+		},
+	}
+}
+
+// versionedTracker sets the resourceVersion on writes of the fake clientset,
+// which leaves it alone, like the fake controller-runtime client does, and
+// rejects updates based on an outdated resourceVersion. Otherwise an update of
+// the controller-runtime client based on an outdated read would silently
+// revert the writes of the clientset.
+type versionedTracker struct {
+	generationTracker
+}
+
+func (t versionedTracker) Create(gvr schema.GroupVersionResource, obj runtime.Object, ns string,
+	opts ...metav1.CreateOptions,
+) error {
+	if accessor, err := meta.Accessor(obj); err == nil && accessor.GetResourceVersion() == "" {
+		accessor.SetResourceVersion("1")
+	}
+
+	return t.generationTracker.Create(gvr, obj, ns, opts...)
+}
+
+// Update leaves the stored object alone if obj does not change it, like the
+// API server, which keeps the resourceVersion and sends no watch event then.
+func (t versionedTracker) Update(gvr schema.GroupVersionResource, obj runtime.Object, ns string,
+	opts ...metav1.UpdateOptions,
+) error {
+	unchanged, err := t.setNextResourceVersion(gvr, obj, ns)
+	if err != nil || unchanged {
+		return err
+	}
+
+	return t.generationTracker.Update(gvr, obj, ns, opts...)
+}
+
+// Patch works like Update.
+func (t versionedTracker) Patch(gvr schema.GroupVersionResource, obj runtime.Object, ns string,
+	opts ...metav1.PatchOptions,
+) error {
+	unchanged, err := t.setNextResourceVersion(gvr, obj, ns)
+	if err != nil || unchanged {
+		return err
+	}
+
+	return t.generationTracker.Patch(gvr, obj, ns, opts...)
+}
+
+func (t versionedTracker) Apply(gvr schema.GroupVersionResource, applyConfiguration runtime.Object, ns string,
+	opts ...metav1.PatchOptions,
+) error {
+	// An applied configuration is partial, so it is always written.
+	if _, err := t.setNextResourceVersion(gvr, applyConfiguration, ns); err != nil {
+		return err
+	}
+
+	return t.generationTracker.Apply(gvr, applyConfiguration, ns, opts...)
+}
+
+// setNextResourceVersion sets the resourceVersion of obj to the one following
+// the stored one, or reports that obj equals the stored object. It returns a
+// Conflict error if obj carries a resourceVersion other than the stored one.
+func (t versionedTracker) setNextResourceVersion(gvr schema.GroupVersionResource, obj runtime.Object,
+	ns string,
+) (bool, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, nil //nolint:nilerr // objects without metadata are left to the tracker
+	}
+
+	existing, err := t.Get(gvr, ns, accessor.GetName())
+	if err != nil {
+		accessor.SetResourceVersion("1")
+
+		return false, nil //nolint:nilerr // the tracker reports missing objects itself
+	}
+
+	existingAccessor, err := meta.Accessor(existing)
+	if err != nil {
+		return false, nil //nolint:nilerr // objects without metadata are left to the tracker
+	}
+
+	stored := existingAccessor.GetResourceVersion()
+	if resourceVersion := accessor.GetResourceVersion(); resourceVersion != "" && resourceVersion != stored {
+		return false, apierrors.NewConflict(gvr.GroupResource(), accessor.GetName(), errObjectModified)
+	}
+
+	accessor.SetResourceVersion(stored)
+
+	if equality.Semantic.DeepEqual(existing, obj) {
+		return true, nil
+	}
+
+	// An empty or unparsable version counts as 0.
+	version, _ := strconv.ParseUint(stored, 10, 64)
+	accessor.SetResourceVersion(strconv.FormatUint(version+1, 10))
+
+	return false, nil
+}
+
+func (t generationTracker) Update(gvr schema.GroupVersionResource, obj runtime.Object, ns string,
+	opts ...metav1.UpdateOptions,
+) error {
+	t.setGeneration(gvr, obj, ns)
+
+	return t.ObjectTracker.Update(gvr, obj, ns, opts...)
+}
+
+func (t generationTracker) Patch(gvr schema.GroupVersionResource, obj runtime.Object, ns string,
+	opts ...metav1.PatchOptions,
+) error {
+	t.setGeneration(gvr, obj, ns)
+
+	return t.ObjectTracker.Patch(gvr, obj, ns, opts...)
+}
+
+// Apply fills in the server defaults of objects created through server-side
+// apply and bumps the generation of existing objects whose applied spec
+// differs from the stored one.
+func (t generationTracker) Apply(gvr schema.GroupVersionResource, applyConfiguration runtime.Object, ns string,
+	opts ...metav1.PatchOptions,
+) error {
+	if applied, ok := applyConfiguration.(*unstructured.Unstructured); ok {
+		t.setApplyGeneration(gvr, applied, ns)
+	}
+
+	return t.ObjectTracker.Apply(gvr, applyConfiguration, ns, opts...)
+}
+
+// setApplyGeneration sets the generation of an applied object. The field
+// manager does not track ownership of the generation, so this does not cause
+// conflicts between appliers.
+func (t generationTracker) setApplyGeneration(gvr schema.GroupVersionResource, applied *unstructured.Unstructured,
+	ns string,
+) {
+	existing, err := t.Get(gvr, ns, applied.GetName())
+	if apierrors.IsNotFound(err) {
+		setApplyDefaults(applied)
+
+		return
+	}
+
+	existingAccessor, err := meta.Accessor(existing)
+	if err != nil {
+		return
+	}
+
+	generation := existingAccessor.GetGeneration()
+	if !containsFields(specOf(existing), applied.Object["spec"]) {
+		generation++
+	}
+
+	applied.SetGeneration(generation)
+}
+
+// setApplyDefaults sets the server defaults on an object created through
+// server-side apply, converting it to its Go type if the scheme knows it.
+func setApplyDefaults(applied *unstructured.Unstructured) {
+	typed, err := SetupScheme().New(applied.GroupVersionKind())
+	if err != nil || runtime.DefaultUnstructuredConverter.FromUnstructured(applied.Object, typed) != nil {
+		setServerDefaults(applied)
+
+		return
+	}
+
+	setServerDefaults(typed)
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return
+	}
+
+	delete(content, "status")
+	applied.Object = content
+}
+
+// containsFields reports whether all fields set in part have the same value in
+// whole, i.e. whether applying part leaves whole unchanged.
+func containsFields(whole, part any) bool {
+	switch p := part.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		w, ok := whole.(map[string]any)
+		if !ok {
+			return false
+		}
+
+		for key, value := range p {
+			if !containsFields(w[key], value) {
+				return false
+			}
+		}
+
+		return true
+	case []any:
+		w, ok := whole.([]any)
+		if !ok || len(w) != len(p) {
+			return false
+		}
+
+		for i := range p {
+			if !containsFields(w[i], p[i]) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return fmt.Sprint(whole) == fmt.Sprint(part)
+	}
+}
+
+// setGeneration sets the generation of obj to the stored one, incremented by
+// one if the spec differs.
+func (t generationTracker) setGeneration(gvr schema.GroupVersionResource, obj runtime.Object, ns string) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	existing, err := t.Get(gvr, ns, accessor.GetName())
+	if err != nil {
+		return
+	}
+
+	existingAccessor, err := meta.Accessor(existing)
+	if err != nil {
+		return
+	}
+
+	generation := existingAccessor.GetGeneration()
+	if !equality.Semantic.DeepEqual(specOf(existing), specOf(obj)) {
+		generation++
+	}
+
+	accessor.SetGeneration(generation)
+}
+
+// specOf returns the spec of obj, or nil for objects without one.
+func specOf(obj runtime.Object) any {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil
+	}
+
+	return content["spec"]
+}
+
+func setServerDefaults(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	if accessor.GetUID() == "" {
+		accessor.SetUID(uuid.NewUUID())
+	}
+
+	if creationTimestamp := accessor.GetCreationTimestamp(); creationTimestamp.IsZero() {
+		accessor.SetCreationTimestamp(metav1.Now())
+	}
+
+	if accessor.GetGeneration() == 0 {
+		accessor.SetGeneration(1)
+	}
+
+	// The status of workloads is ignored on create, it belongs to their
+	// controllers.
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		o.Status = appsv1.DeploymentStatus{}
+
+		if o.Spec.Replicas == nil {
+			o.Spec.Replicas = int32Ptr(1)
+		}
+	case *appsv1.StatefulSet:
+		o.Status = appsv1.StatefulSetStatus{}
+
+		if o.Spec.Replicas == nil {
+			o.Spec.Replicas = int32Ptr(1)
+		}
+	case *appsv1.DaemonSet:
+		o.Status = appsv1.DaemonSetStatus{}
+	case *corev1.Service:
+		setServiceDefaults(o)
+	case *batchv1.Job:
+		o.Status = batchv1.JobStatus{}
+
+		setJobDefaults(o)
+	case *batchv1.CronJob:
+		o.Status = batchv1.CronJobStatus{}
+	}
+}
+
+// setJobDefaults generates the selector and pod labels of a Job the way the
+// API server does when the selector is not set manually.
+func setJobDefaults(job *batchv1.Job) {
+	if job.Spec.Selector != nil {
+		return
+	}
+
+	labels := map[string]string{
+		batchv1.ControllerUidLabel: string(job.UID),
+		batchv1.JobNameLabel:       job.Name,
+		"controller-uid":           string(job.UID),
+		"job-name":                 job.Name,
+	}
+
+	job.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{batchv1.ControllerUidLabel: string(job.UID)},
+	}
+
+	if job.Spec.Template.Labels == nil {
+		job.Spec.Template.Labels = map[string]string{}
+	}
+
+	maps.Copy(job.Spec.Template.Labels, labels)
+}
+
+// setServiceDefaults assigns a cluster IP and node ports the way the API server
+// allocates them.
+func setServiceDefaults(service *corev1.Service) {
+	if service.Spec.Type == "" {
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+	}
+
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return
+	}
+
+	if service.Spec.ClusterIP == "" {
+		service.Spec.ClusterIP = fmt.Sprintf("10.96.%d.%d", utilrand.Intn(256), 1+utilrand.Intn(254))
+	}
+
+	if service.Spec.Type != corev1.ServiceTypeNodePort && service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return
+	}
+
+	for i := range service.Spec.Ports {
+		if service.Spec.Ports[i].NodePort == 0 {
+			service.Spec.Ports[i].NodePort = int32(30000 + utilrand.Intn(2768))
+		}
+	}
+}
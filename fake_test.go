@@ -0,0 +1,160 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestFakeTestClients(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace()).
+		WithDeployment("deployment-fake-1").
+		WithConfigMap("config-map-fake-1").
+		WithSecret("secret-fake-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment, err := resources.TestClients.ClientSet.AppsV1().Deployments(resources.Namespace).Get(
+		context.Background(), "deployment-fake-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment from fake clientset: %v", err)
+	}
+
+	if len(deployment.Spec.Template.Spec.Volumes) != 2 {
+		t.Errorf("Expected 2 volumes on the deployment, got %d", len(deployment.Spec.Template.Spec.Volumes))
+	}
+
+	cm := resources.ConfigMaps[0]
+	cm.Data["new-key"] = "new-value"
+
+	_, err = resources.Update(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updatedCm, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-fake-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updatedCm.Data["new-key"] != "new-value" {
+		t.Errorf("Expected update through the controller-runtime client to be visible in the clientset, got %v",
+			updatedCm.Data)
+	}
+
+	err = resources.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.TestClients.ClientSet.AppsV1().Deployments(resources.Namespace).Get(
+		context.Background(), "deployment-fake-1", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected deployment to be deleted, got %v", err)
+	}
+}
+
+func TestFakeTestClientsConcurrentWrites(t *testing.T) {
+	testClients := NewFakeTestClients()
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: DefaultNamespace, Name: "config-map-fake-2"}
+
+	err := testClients.K8sClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Data:       map[string]string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const writes = 20
+
+	// Updates based on an outdated read conflict and are retried until every
+	// concurrent patch has been written.
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3 * writes}
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 3*writes)
+
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+
+		for i := range writes {
+			errs <- retry.RetryOnConflict(backoff, func() error {
+				configMap := &corev1.ConfigMap{}
+				if err := testClients.K8sClient.Get(ctx, key, configMap); err != nil {
+					return err
+				}
+
+				if configMap.Data == nil {
+					configMap.Data = map[string]string{}
+				}
+
+				configMap.Data[fmt.Sprintf("update-%d", i)] = "true"
+
+				return testClients.K8sClient.Update(ctx, configMap)
+			})
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := range writes {
+			patch := fmt.Appendf(nil, `{"data":{"client-patch-%d":"true"}}`, i)
+			errs <- testClients.K8sClient.Patch(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			}, client.RawPatch(types.MergePatchType, patch))
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := range writes {
+			patch := fmt.Appendf(nil, `{"data":{"clientset-patch-%d":"true"}}`, i)
+			_, err := testClients.ClientSet.CoreV1().ConfigMaps(key.Namespace).Patch(ctx, key.Name,
+				types.MergePatchType, patch, metav1.PatchOptions{})
+			errs <- err
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configMap, err := testClients.ClientSet.CoreV1().ConfigMaps(key.Namespace).Get(ctx, key.Name,
+		metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range writes {
+		for _, prefix := range []string{"update", "client-patch", "clientset-patch"} {
+			if name := fmt.Sprintf("%s-%d", prefix, i); configMap.Data[name] != "true" {
+				t.Errorf("Expected the write of %s to be kept", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package k8stest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// UpdateGoldenEnv is the environment variable that makes AssertGolden write
+	// the golden files instead of comparing with them when set to true. It is
+	// the fallback for test binaries that do not define the -update flag.
+	UpdateGoldenEnv  = "K8STEST_UPDATE_GOLDEN"
+	updateGoldenFlag = "update"
+	diffContextLines = 3
+)
+
+// AssertGolden compares the manifest rendered for resources with the golden
+// file at path and fails the test with a per-object, field-ordered diff when
+// they differ. Running the tests with -update writes the golden file instead.
+// The flag is not registered by this package, so that it does not clash with
+// flags of the test binary; define it in the test package with
+//
+//	var _ = flag.Bool("update", false, "update golden files")
+//
+// or set K8STEST_UPDATE_GOLDEN=true. The namespace of resources is left out of
+// the manifest, so that golden files also work with per-test namespaces.
+func AssertGolden(t *testing.T, resources *Resources, path string) {
+	t.Helper()
+
+	actual, err := resources.renderGolden()
+	if err != nil {
+		t.Fatalf("Failed to render resources: %v", err)
+	}
+
+	if shouldUpdateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("Failed to create directory for golden file %s: %v", path, err)
+		}
+
+		if err := os.WriteFile(path, actual, 0o600); err != nil {
+			t.Fatalf("Failed to write golden file %s: %v", path, err)
+		}
+
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s, run with -%s to create it: %v", path, updateGoldenFlag, err)
+	}
+
+	diff, err := goldenDiff(expected, actual)
+	if err != nil {
+		t.Fatalf("Failed to compare with golden file %s: %v", path, err)
+	}
+
+	if diff != "" {
+		t.Errorf("Resources differ from golden file %s, run with -%s to accept the changes:\n%s",
+			path, updateGoldenFlag, diff)
+	}
+}
+
+// shouldUpdateGolden reports whether the -update flag of the test binary or
+// else UpdateGoldenEnv is set to true.
+func shouldUpdateGolden() bool {
+	value := os.Getenv(UpdateGoldenEnv)
+
+	if updateFlag := flag.Lookup(updateGoldenFlag); updateFlag != nil && updateFlag.Value.String() != "false" {
+		value = updateFlag.Value.String()
+	}
+
+	update, err := strconv.ParseBool(value)
+
+	return err == nil && update
+}
+
+// renderGolden renders r like MarshalYAML but without the namespace of r.
+func (r *Resources) renderGolden() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	for i, obj := range r.orderedObjects() {
+		document, err := renderObject(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		rendered := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(document, &rendered.Object); err != nil {
+			return nil, err
+		}
+
+		if rendered.GetNamespace() == r.namespace() {
+			unstructured.RemoveNestedField(rendered.Object, "metadata", "namespace")
+		}
+
+		if document, err = yaml.Marshal(rendered.Object); err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buffer.WriteString(yamlDocumentSeparator)
+		}
+
+		buffer.Write(document)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// goldenDocument is one object of a manifest, normalized to sorted fields.
+type goldenDocument struct {
+	key   string
+	lines []string
+}
+
+// goldenDiff returns a diff per object between two manifests, or an empty
+// string if they contain the same objects. Objects are matched by kind,
+// namespace and name, so reordering alone is no difference.
+func goldenDiff(expected, actual []byte) (string, error) {
+	expectedDocuments, err := parseGoldenDocuments(expected)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse expected manifest: %w", err)
+	}
+
+	actualDocuments, err := parseGoldenDocuments(actual)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse actual manifest: %w", err)
+	}
+
+	expectedByKey := map[string]goldenDocument{}
+	for _, document := range expectedDocuments {
+		expectedByKey[document.key] = document
+	}
+
+	var diff strings.Builder
+
+	for _, document := range actualDocuments {
+		expectedDocument, found := expectedByKey[document.key]
+		if !found {
+			fmt.Fprintf(&diff, "%s: not in golden file\n", document.key)
+
+			continue
+		}
+
+		delete(expectedByKey, document.key)
+
+		if lines := diffLines(expectedDocument.lines, document.lines); lines != "" {
+			fmt.Fprintf(&diff, "%s:\n%s", document.key, lines)
+		}
+	}
+
+	for _, document := range expectedDocuments {
+		if _, missing := expectedByKey[document.key]; missing {
+			fmt.Fprintf(&diff, "%s: missing from resources\n", document.key)
+		}
+	}
+
+	return diff.String(), nil
+}
+
+func parseGoldenDocuments(manifest []byte) ([]goldenDocument, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), yamlBufferSize)
+
+	var documents []goldenDocument
+
+	for {
+		obj := &unstructured.Unstructured{}
+
+		err := decoder.Decode(&obj.Object)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		normalized, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		key := obj.GetKind() + " " + obj.GetName()
+		if obj.GetNamespace() != "" {
+			key = obj.GetKind() + " " + obj.GetNamespace() + "/" + obj.GetName()
+		}
+
+		documents = append(documents, goldenDocument{
+			key:   key,
+			lines: strings.Split(strings.TrimSuffix(string(normalized), "\n"), "\n"),
+		})
+	}
+}
+
+// diffLines returns a line diff of two texts in unified style with a few lines
+// of context around every change, or an empty string if they are equal.
+func diffLines(expected, actual []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// expected[i:] and actual[j:].
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actual)+1)
+	}
+
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+
+	changed := false
+
+	for i, j := 0, 0; i < len(expected) || j < len(actual); {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, "  "+expected[i])
+			i++
+			j++
+		case i < len(expected) && (j == len(actual) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+expected[i])
+			i++
+			changed = true
+		default:
+			lines = append(lines, "+ "+actual[j])
+			j++
+			changed = true
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+
+	return withContext(lines)
+}
+
+// withContext keeps the changed lines and diffContextLines unchanged lines
+// around them, replacing longer unchanged stretches by "...".
+func withContext(lines []string) string {
+	keep := make([]bool, len(lines))
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, "  ") {
+			continue
+		}
+
+		for j := max(i-diffContextLines, 0); j <= min(i+diffContextLines, len(lines)-1); j++ {
+			keep[j] = true
+		}
+	}
+
+	var result strings.Builder
+
+	skipped := false
+
+	for i, line := range lines {
+		if !keep[i] {
+			skipped = true
+
+			continue
+		}
+
+		if skipped {
+			result.WriteString("  ...\n")
+
+			skipped = false
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	if skipped {
+		result.WriteString("  ...\n")
+	}
+
+	return result.String()
+}
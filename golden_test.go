@@ -0,0 +1,126 @@
+package k8stest
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The -update flag of AssertGolden is defined by the test binary.
+var _ = flag.Bool("update", false, "update the golden files of AssertGolden")
+
+func TestAssertGolden(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace()).
+		WithResourceOption(ZeroTerminationGracePeriodOption()).
+		WithDeployment("web").
+		WithConfigMap("web-config").
+		WithSecret("web-secret").
+		And().
+		WithStatefulSet("db").
+		And()
+
+	AssertGolden(t, resources, "testdata/golden/web-and-db.golden.yaml")
+}
+
+func TestAssertGoldenUpdate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+	}{
+		{
+			name: "Flag",
+			setup: func(t *testing.T) {
+				updateFlag := flag.Lookup("update")
+				previous := updateFlag.Value.String()
+
+				if err := updateFlag.Value.Set("true"); err != nil {
+					t.Fatal(err)
+				}
+
+				t.Cleanup(func() { _ = updateFlag.Value.Set(previous) })
+			},
+		},
+		{
+			name: "Environment variable",
+			setup: func(t *testing.T) {
+				t.Setenv(UpdateGoldenEnv, "true")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+
+			resources := NewWithClients(t, context.Background(), NewFakeTestClients()).
+				WithConfigMap("web-config")
+
+			path := filepath.Join(t.TempDir(), "golden", "web.golden.yaml")
+
+			AssertGolden(t, resources, path)
+
+			golden, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Expected the golden file to be written: %v", err)
+			}
+
+			if !strings.Contains(string(golden), "name: web-config") {
+				t.Errorf("Expected the golden file to contain the configmap, got:\n%s", golden)
+			}
+		})
+	}
+}
+
+func TestGoldenDiff(t *testing.T) {
+	expected := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+`
+	actual := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: added
+---
+apiVersion: v1
+data:
+  key: changed
+kind: ConfigMap
+metadata:
+  name: a
+`
+
+	diff, err := goldenDiff([]byte(expected), []byte(actual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"ConfigMap added: not in golden file",
+		"ConfigMap removed: missing from resources",
+		"ConfigMap a:\n  apiVersion: v1\n  data:\n-   key: value\n+   key: changed\n",
+	} {
+		if !strings.Contains(diff, line) {
+			t.Errorf("Expected diff to contain %q, got:\n%s", line, diff)
+		}
+	}
+
+	diff, err = goldenDiff([]byte(expected), []byte(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff != "" {
+		t.Errorf("Expected no diff for equal manifests, got:\n%s", diff)
+	}
+}
@@ -0,0 +1,33 @@
+package k8stest
+
+import (
+	"k8s.io/client-go/kubernetes"
+	ctrclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// BuildEnvTestClients starts the given envtest environment, i.e. a local
+// kube-apiserver and etcd, installs its CRDs and creates a Kubernetes clientset
+// and a controller-runtime client for it. The environment is stopped again if
+// the clients cannot be built.
+//
+//nolint:ireturn // Returning controller-runtime client interface is intentional
+func BuildEnvTestClients(environment *envtest.Environment) (*kubernetes.Clientset, ctrclient.Client, error) {
+	if environment.Scheme == nil {
+		environment.Scheme = NewScheme()
+	}
+
+	cfg, err := environment.Start()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientset, k8sClient, err := BuildClientsForConfig(cfg)
+	if err != nil {
+		_ = environment.Stop()
+
+		return nil, nil, err
+	}
+
+	return clientset, k8sClient, nil
+}
@@ -2,9 +2,11 @@ package k8stest
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -15,27 +17,48 @@ import (
 //
 //nolint:ireturn // Returning controller-runtime client interface is intentional
 func BuildClients() (*kubernetes.Clientset, ctrclient.Client, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return BuildClientsForConfig(cfg)
+}
+
+// LoadConfig loads the REST config of the current kubeconfig context.
+func LoadConfig() (*rest.Config, error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	configOverrides := &clientcmd.ConfigOverrides{}
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-	cfg, err := kubeConfig.ClientConfig()
+
+	return kubeConfig.ClientConfig()
+}
+
+// BuildClientsForConfig creates a Kubernetes clientset and a controller-runtime
+// client for the given REST config. The controller-runtime client supports
+// watches.
+//
+//nolint:ireturn // Returning controller-runtime client interface is intentional
+func BuildClientsForConfig(cfg *rest.Config) (*kubernetes.Clientset, ctrclient.Client, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	clientset, err := kubernetes.NewForConfig(cfg)
+	k8sClient, err := ctrclient.NewWithWatch(cfg, ctrclient.Options{Scheme: NewScheme()})
 	if err != nil {
 		return nil, nil, err
 	}
 
+	return clientset, k8sClient, nil
+}
+
+// NewScheme returns a scheme with all API groups the library works with.
+func NewScheme() *runtime.Scheme {
 	scheme := runtime.NewScheme()
 	_ = appsv1.AddToScheme(scheme)
+	_ = batchv1.AddToScheme(scheme)
 	_ = corev1.AddToScheme(scheme)
 
-	k8sClient, err := ctrclient.New(cfg, ctrclient.Options{Scheme: scheme})
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return clientset, k8sClient, nil
+	return scheme
 }
@@ -0,0 +1,336 @@
+package k8stest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	defaultCronJobSchedule = "*/1 * * * *"
+	// cronJobInstantiateAnnotation marks Jobs created from a CronJob by hand,
+	// just like kubectl create job --from=cronjob/... does.
+	cronJobInstantiateAnnotation = "cronjob.kubernetes.io/instantiate"
+)
+
+var (
+	// ErrJobFailed is returned when a Job that was expected to succeed failed.
+	ErrJobFailed = errors.New("job failed")
+	// ErrJobSucceeded is returned when a Job that was expected to fail succeeded.
+	ErrJobSucceeded = errors.New("job succeeded")
+)
+
+// JobOutcome is the final state WaitForJob waits for.
+type JobOutcome int
+
+const (
+	// JobSucceeded waits until the Job is complete.
+	JobSucceeded JobOutcome = iota
+	// JobFailed waits until the Job has failed.
+	JobFailed
+)
+
+// ContainerResult describes how a container of a finished pod terminated.
+type ContainerResult struct {
+	Pod       string
+	Container string
+	ExitCode  int32
+	Reason    string
+	Message   string
+}
+
+type Job struct {
+	Resources
+}
+
+type CronJob struct {
+	Resources
+}
+
+// createJobPodTemplateSpec returns the pod template of createPodTemplateSpec
+// with a container that writes to the termination log and exits, so that the
+// Job completes.
+func createJobPodTemplateSpec(name string) corev1.PodTemplateSpec {
+	template := createPodTemplateSpec(name)
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	template.Spec.Containers[0].Command = []string{
+		"sh",
+		"-c",
+		"echo Completed > /dev/termination-log",
+	}
+
+	return template
+}
+
+func (r *Resources) WithJob(name string) *Job {
+	r.Jobs = append(r.Jobs, &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace(),
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Template: createJobPodTemplateSpec(name),
+		},
+	})
+
+	r.ApplyOptions(r.Jobs[len(r.Jobs)-1])
+
+	return &Job{*r}
+}
+
+// WithCronJob adds a CronJob that runs every minute. Use TriggerCronJob to run
+// it right away.
+func (r *Resources) WithCronJob(name string) *CronJob {
+	r.CronJobs = append(r.CronJobs, &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CronJob",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace(),
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:          defaultCronJobSchedule,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: batchv1.JobSpec{
+					Template: createJobPodTemplateSpec(name),
+				},
+			},
+		},
+	})
+
+	r.ApplyOptions(r.CronJobs[len(r.CronJobs)-1])
+
+	return &CronJob{*r}
+}
+
+func (j *Job) WithSecret(name string) *Job {
+	resources := &j.Resources
+	resources.WithSecret(name)
+
+	job := j.Jobs[len(j.Jobs)-1]
+	attachSecretVolume(&job.Spec.Template.Spec, name)
+
+	return j
+}
+
+func (j *Job) WithConfigMap(name string) *Job {
+	resources := &j.Resources
+	resources.WithConfigMap(name)
+
+	job := j.Jobs[len(j.Jobs)-1]
+	attachConfigMapVolume(&job.Spec.Template.Spec, name)
+
+	return j
+}
+
+func (j *Job) And() *Resources {
+	return &j.Resources
+}
+
+func (c *CronJob) WithSecret(name string) *CronJob {
+	resources := &c.Resources
+	resources.WithSecret(name)
+
+	cronJob := c.CronJobs[len(c.CronJobs)-1]
+	attachSecretVolume(&cronJob.Spec.JobTemplate.Spec.Template.Spec, name)
+
+	return c
+}
+
+func (c *CronJob) WithConfigMap(name string) *CronJob {
+	resources := &c.Resources
+	resources.WithConfigMap(name)
+
+	cronJob := c.CronJobs[len(c.CronJobs)-1]
+	attachConfigMapVolume(&cronJob.Spec.JobTemplate.Spec.Template.Spec, name)
+
+	return c
+}
+
+func (c *CronJob) And() *Resources {
+	return &c.Resources
+}
+
+// createJobCopy works like createCopy and also drops the selector and pod
+// labels the API server generated, which it rejects on create.
+func createJobCopy(job *batchv1.Job) *batchv1.Job {
+	fresh := createCopy(job)
+	if fresh.Spec.ManualSelector != nil && *fresh.Spec.ManualSelector {
+		return fresh
+	}
+
+	fresh.Spec.Selector = nil
+
+	for _, label := range []string{batchv1.ControllerUidLabel, batchv1.JobNameLabel, "controller-uid", "job-name"} {
+		delete(fresh.Spec.Template.Labels, label)
+	}
+
+	return fresh
+}
+
+// WaitForJob waits until the Job with the given name has the expected outcome.
+// It returns early with ErrJobFailed or ErrJobSucceeded when the Job finished
+// the other way.
+func (r *Resources) WaitForJob(name string, outcome JobOutcome, timeout ...time.Duration) (*batchv1.Job, error) {
+	applicableTimeout := r.Timeout
+
+	if len(timeout) > 0 {
+		applicableTimeout = timeout[0]
+	}
+
+	var job *batchv1.Job
+
+	hasOutcome := func(current *batchv1.Job) (bool, error) {
+		job = current
+
+		return jobHasOutcome(current, outcome)
+	}
+
+	jobs := r.TestClients.ClientSet.BatchV1().Jobs(r.namespace())
+	targets, sources := addWaitTargets(nil, nil, "job", r.namespace(),
+		[]*batchv1.Job{{ObjectMeta: metav1.ObjectMeta{Name: name}}}, jobs.Get, jobs.Watch, hasOutcome)
+
+	return job, r.waitFor(applicableTimeout, targets, sources)
+}
+
+// TriggerCronJob creates a Job from the job template of the CronJob with the
+// given name and returns it, like kubectl create job --from=cronjob/<name>.
+// The Job is deleted together with the other resources.
+func (r *Resources) TriggerCronJob(name string) (*batchv1.Job, error) {
+	r.registerCleanup()
+
+	cronJob, err := r.TestClients.ClientSet.BatchV1().CronJobs(r.namespace()).Get(*r.Ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cronjob %s: %w", name, err)
+	}
+
+	annotations := maps.Clone(cronJob.Spec.JobTemplate.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[cronJobInstantiateAnnotation] = "manual"
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name + "-manual-" + utilrand.String(5),
+			Namespace:   r.namespace(),
+			Labels:      maps.Clone(cronJob.Spec.JobTemplate.Labels),
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: batchv1.SchemeGroupVersion.String(),
+				Kind:       "CronJob",
+				Name:       cronJob.Name,
+				UID:        cronJob.UID,
+				Controller: boolPtr(true),
+			}},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}
+
+	created, err := r.TestClients.ClientSet.BatchV1().Jobs(r.namespace()).Create(*r.Ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job from cronjob %s: %w", name, err)
+	}
+
+	created.TypeMeta = job.TypeMeta
+	r.track(created)
+
+	return created, nil
+}
+
+// JobPods returns the pods of the Job with the given name, see Pods.
+func (r *Resources) JobPods(name string) ([]corev1.Pod, error) {
+	return r.Pods(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: r.namespace()}})
+}
+
+// JobResults returns the exit code, reason and termination message of every
+// terminated container of the pods of the Job with the given name.
+func (r *Resources) JobResults(name string) ([]ContainerResult, error) {
+	pods, err := r.JobPods(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ContainerResult
+
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			terminated := containerStatus.State.Terminated
+			if terminated == nil {
+				continue
+			}
+
+			results = append(results, ContainerResult{
+				Pod:       pod.Name,
+				Container: containerStatus.Name,
+				ExitCode:  terminated.ExitCode,
+				Reason:    terminated.Reason,
+				Message:   terminated.Message,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// jobHasOutcome reports whether job finished with the expected outcome and
+// returns an error if it finished with the other one.
+func jobHasOutcome(job *batchv1.Job, outcome JobOutcome) (bool, error) {
+	condition := jobFinishedCondition(job)
+	if condition == nil {
+		return false, nil
+	}
+
+	switch {
+	case condition.Type == batchv1.JobComplete && outcome == JobFailed:
+		return false, ErrJobSucceeded
+	case condition.Type == batchv1.JobFailed && outcome == JobSucceeded:
+		return false, fmt.Errorf("%w: %s: %s", ErrJobFailed, condition.Reason, condition.Message)
+	}
+
+	return true, nil
+}
+
+func isJobFinished(job *batchv1.Job) bool {
+	return jobFinishedCondition(job) != nil
+}
+
+func jobFinishedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
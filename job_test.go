@@ -0,0 +1,126 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// FailingImageOption returns a ResourceOption that lets the containers of
+// Jobs run an image the Simulator lets fail with ReasonError.
+func FailingImageOption(image string) ResourceOption {
+	return func(obj runtime.Object) {
+		job, ok := obj.(*batchv1.Job)
+		if !ok {
+			return
+		}
+
+		job.Spec.Template.Spec.Containers[0].Image = image
+		job.Spec.BackoffLimit = int32Ptr(1)
+	}
+}
+
+func TestJob(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithJob("job-1").
+		WithConfigMap("config-map-job-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resources.Jobs[0].Spec.Template.Spec.Volumes) != 1 {
+		t.Errorf("Expected the configmap to be mounted into the job pods")
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := resources.JobResults("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].ExitCode != 0 || results[0].Reason != "Completed" {
+		t.Errorf("Expected one container that completed with exit code 0, got %+v", results)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	failingImage := "failing-image:latest"
+
+	resources, err := NewFake(t, context.Background(),
+		WithSimulator(WithImageFailure(failingImage, ReasonError))).
+		WithResourceOption(FailingImageOption(failingImage)).
+		WithJob("job-failing-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if !errors.Is(err, ErrJobFailed) {
+		t.Fatalf("Expected Wait to fail with ErrJobFailed, got %v", err)
+	}
+
+	_, err = resources.WaitForJob("job-failing-1", JobFailed, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := resources.JobResults("job-failing-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected two failed attempts with a backoff limit of 1, got %+v", results)
+	}
+
+	for _, result := range results {
+		if result.ExitCode != 1 {
+			t.Errorf("Expected exit code 1, got %+v", result)
+		}
+	}
+}
+
+func TestTriggerCronJob(t *testing.T) {
+	resources, err := NewFake(t, context.Background()).
+		WithCronJob("cronjob-1").
+		WithSecret("secret-cronjob-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := resources.TriggerCronJob("cronjob-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.WaitForJob(job.Name, JobSucceeded, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.WaitForJob(job.Name, JobFailed, 2*time.Second)
+	if !errors.Is(err, ErrJobSucceeded) {
+		t.Errorf("Expected ErrJobSucceeded when waiting for a failure, got %v", err)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -7,12 +7,13 @@ import (
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	k8sinternal "github.com/tom1299/k8stest/internal"
@@ -21,22 +22,76 @@ import (
 type Resources struct {
 	Deployments  []*appsv1.Deployment
 	StatefulSets []*appsv1.StatefulSet
+	DaemonSets   []*appsv1.DaemonSet
 	ConfigMaps   []*corev1.ConfigMap
 	Secrets      []*corev1.Secret
+	Services     []*corev1.Service
+	Jobs         []*batchv1.Job
+	CronJobs     []*batchv1.CronJob
+	Objects      []*TrackedObject
 	Options      []ResourceOption
 	TestClients  *TestClients
 	Ctx          *context.Context
 	Timeout      time.Duration
+	Namespace    string
+	// WaitForDeletion makes Delete, Create and the automatic cleanup block
+	// until all resources are gone, see DeleteAndWait.
+	WaitForDeletion bool
+	// WaitForRollout makes Wait wait for the rollouts of Deployments and
+	// StatefulSets to complete, see WithRolloutWait.
+	WaitForRollout bool
+	// CleanupPolicy decides whether resources are deleted through t.Cleanup
+	// when the test ends.
+	CleanupPolicy CleanupPolicy
+	// ServerSideApply makes Create and Update use server-side apply with
+	// FieldManager, see WithServerSideApply.
+	ServerSideApply bool
+	FieldManager    string
+	// ForceOwnership makes server-side apply take over fields owned by other
+	// field managers.
+	ForceOwnership bool
+
+	// Simulator emulates workload controllers when running without a cluster,
+	// see NewFake and WithSimulator.
+	Simulator *Simulator
+
+	t                 *testing.T
+	cleanupRegistered bool
+	untracked         []client.Object
+	applied           map[client.Object]*appliedState
 }
 
+// DefaultNamespace is the namespace used when no per-test namespace is requested.
+const DefaultNamespace = "default"
+
+// Option configures a Resources object created by New.
+type Option func(t *testing.T, r *Resources)
+
 // New creates a new Resources object with the given TestClients and context.
-// It initializes the Timeout to the default value of 30 seconds.
-func New(t *testing.T, ctx context.Context) *Resources {
-	return &Resources{
-		TestClients: SetupTestClients(t),
+// It initializes the Timeout to the default value of 30 seconds and targets the
+// "default" namespace unless an Option such as WithTestNamespace says otherwise.
+// Resources created through Create are deleted via t.Cleanup according to the
+// CleanupPolicy, so tests do not need to call Delete themselves.
+func New(t *testing.T, ctx context.Context, opts ...Option) *Resources {
+	return NewWithClients(t, ctx, SetupTestClients(t), opts...)
+}
+
+// NewWithClients works like New but uses the given TestClients instead of
+// loading the current kubeconfig, e.g. clients created by NewFakeTestClients.
+func NewWithClients(t *testing.T, ctx context.Context, testClients *TestClients, opts ...Option) *Resources {
+	r := &Resources{
+		TestClients: testClients,
 		Ctx:         &ctx,
 		Timeout:     30 * time.Second,
+		Namespace:   DefaultNamespace,
+		t:           t,
 	}
+
+	for _, opt := range opts {
+		opt(t, r)
+	}
+
+	return r
 }
 
 type Deployment struct {
@@ -53,6 +108,10 @@ func boolPtr(b bool) *bool {
 	return &b
 }
 
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
 func createPodTemplateSpec(name string) corev1.PodTemplateSpec {
 	return corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
@@ -116,97 +175,140 @@ func attachConfigMapVolume(podSpec *corev1.PodSpec, configMapName string) {
 	})
 }
 
+// Create deletes leftovers of the tracked resources and creates them again.
+// With WaitForDeletion set, leftovers are gone before anything is re-created.
+// The objects returned by the API server are written back into r, so the
+// objects in the slices carry their UID, resourceVersion and defaults.
+// In server-side apply mode, existing objects are kept and the resources are
+// applied on top of them instead.
 func (r *Resources) Create() (*Resources, error) {
+	r.registerCleanup()
+
+	if r.ServerSideApply {
+		if err := r.applyAll(*r.Ctx); err != nil {
+			return nil, err
+		}
+
+		return r, nil
+	}
+
 	err := r.Delete()
 	if err != nil {
 		return nil, err
 	}
 	for _, configMap := range r.ConfigMaps {
-		_, err := r.TestClients.ClientSet.CoreV1().ConfigMaps("default").Create(
-			*r.Ctx, configMap, metav1.CreateOptions{})
+		created, err := r.TestClients.ClientSet.CoreV1().ConfigMaps(r.namespace()).Create(
+			*r.Ctx, createCopy(configMap), metav1.CreateOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create configmap: %w", err)
 		}
+
+		writeBack(configMap, created)
 	}
 	for _, secret := range r.Secrets {
-		_, err := r.TestClients.ClientSet.CoreV1().Secrets("default").Create(
-			*r.Ctx, secret, metav1.CreateOptions{})
+		created, err := r.TestClients.ClientSet.CoreV1().Secrets(r.namespace()).Create(
+			*r.Ctx, createCopy(secret), metav1.CreateOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create secret: %w", err)
 		}
+
+		writeBack(secret, created)
+	}
+
+	if err := r.createObjects(); err != nil {
+		return nil, err
+	}
+
+	for _, service := range r.Services {
+		created, err := r.TestClients.ClientSet.CoreV1().Services(r.namespace()).Create(
+			*r.Ctx, createCopy(service), metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create service: %w", err)
+		}
+
+		writeBack(service, created)
 	}
 
 	for _, deployment := range r.Deployments {
-		_, err := r.TestClients.ClientSet.AppsV1().Deployments("default").Create(
-			*r.Ctx, deployment, metav1.CreateOptions{})
+		created, err := r.TestClients.ClientSet.AppsV1().Deployments(r.namespace()).Create(
+			*r.Ctx, createCopy(deployment), metav1.CreateOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create deployment: %w", err)
 		}
+
+		writeBack(deployment, created)
 	}
 
 	for _, statefulSet := range r.StatefulSets {
-		_, err := r.TestClients.ClientSet.AppsV1().StatefulSets("default").Create(
-			*r.Ctx, statefulSet, metav1.CreateOptions{})
+		created, err := r.TestClients.ClientSet.AppsV1().StatefulSets(r.namespace()).Create(
+			*r.Ctx, createCopy(statefulSet), metav1.CreateOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create statefulset: %w", err)
 		}
-	}
 
-	return r, nil
-}
-
-func (r *Resources) Wait(timeout ...time.Duration) error {
-	applicableTimeout := r.Timeout
+		writeBack(statefulSet, created)
+	}
 
-	startTime := time.Now()
+	for _, daemonSet := range r.DaemonSets {
+		created, err := r.TestClients.ClientSet.AppsV1().DaemonSets(r.namespace()).Create(
+			*r.Ctx, createCopy(daemonSet), metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create daemonset: %w", err)
+		}
 
-	if len(timeout) > 0 {
-		applicableTimeout = timeout[0]
+		writeBack(daemonSet, created)
 	}
 
-	for _, deployment := range r.Deployments {
-		err := wait.PollUntilContextTimeout(*r.Ctx, 100*time.Millisecond, applicableTimeout, true,
-			func(ctx context.Context) (bool, error) {
-				dep, err := r.TestClients.ClientSet.AppsV1().Deployments("default").Get(
-					ctx, deployment.Name, metav1.GetOptions{})
+	for _, job := range r.Jobs {
+		created, err := r.TestClients.ClientSet.BatchV1().Jobs(r.namespace()).Create(
+			*r.Ctx, createJobCopy(job), metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create job: %w", err)
+		}
 
-				if err != nil {
-					return false, err
-				}
+		writeBack(job, created)
+	}
 
-				return dep.Status.AvailableReplicas == *dep.Spec.Replicas, nil
-			})
+	for _, cronJob := range r.CronJobs {
+		created, err := r.TestClients.ClientSet.BatchV1().CronJobs(r.namespace()).Create(
+			*r.Ctx, createCopy(cronJob), metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("failed to wait for deployment %s: %w", deployment.Name, err)
+			return nil, fmt.Errorf("failed to create cronjob: %w", err)
 		}
-	}
 
-	remainingTime := applicableTimeout - time.Since(startTime)
+		writeBack(cronJob, created)
+	}
 
-	for _, statefulSet := range r.StatefulSets {
-		err := wait.PollUntilContextTimeout(*r.Ctx, 100*time.Millisecond, remainingTime, true,
-			func(ctx context.Context) (bool, error) {
-				sts, err := r.TestClients.ClientSet.AppsV1().StatefulSets("default").Get(
-					ctx, statefulSet.Name, metav1.GetOptions{})
+	return r, nil
+}
 
-				if err != nil {
-					return false, err
-				}
+// Wait blocks until all Deployments, StatefulSets and DaemonSets are ready, all
+// Services are set up, all Jobs succeeded and all objects added through
+// WithObject pass their readiness checks. A failed Job ends the wait with
+// ErrJobFailed. With WaitForRollout set, Deployments and StatefulSets must also
+// have completed their rollout. All resources are waited for concurrently
+// within the same timeout, and on timeout the error lists every resource that
+// was not ready. Collecting its diagnostics may take up to two seconds more.
+// When the context of r is cancelled, the wait ends right away with an error
+// that wraps context.Canceled and carries no diagnostics.
+func (r *Resources) Wait(timeout ...time.Duration) error {
+	applicableTimeout := r.Timeout
 
-				return sts.Spec.Replicas != nil && sts.Status.ReadyReplicas == *sts.Spec.Replicas, nil
-			})
-		if err != nil {
-			return fmt.Errorf("failed to wait for statefulset %s: %w", statefulSet.Name, err)
-		}
+	if len(timeout) > 0 {
+		applicableTimeout = timeout[0]
 	}
 
-	return nil
+	targets, sources := r.waitTargets()
+
+	return r.waitFor(applicableTimeout, targets, sources)
 }
 
 type deleteFunc func(ctx context.Context, name string, opts metav1.DeleteOptions) error
 
-func deleteResource(ctx context.Context, name, resourceType string, deleter deleteFunc) error {
-	err := deleter(ctx, name, metav1.DeleteOptions{})
+func deleteResource(ctx context.Context, name, resourceType string, deleter deleteFunc,
+	opts metav1.DeleteOptions,
+) error {
+	err := deleter(ctx, name, opts)
 	if err != nil && !apierrors.IsNotFound(err) {
 		return fmt.Errorf("failed to delete %s: %w", resourceType, err)
 	}
@@ -214,31 +316,88 @@ func deleteResource(ctx context.Context, name, resourceType string, deleter dele
 	return nil
 }
 
+// Delete deletes all tracked resources. Resources that do not exist are ignored.
+// If WaitForDeletion is set, Delete blocks like DeleteAndWait.
 func (r *Resources) Delete() error {
+	return r.teardown(*r.Ctx)
+}
+
+func (r *Resources) teardown(ctx context.Context) error {
+	if r.WaitForDeletion {
+		return r.deleteAndWait(ctx, r.Timeout)
+	}
+
+	return r.delete(ctx, metav1.DeleteOptions{})
+}
+
+func (r *Resources) delete(ctx context.Context, opts metav1.DeleteOptions) error {
+	// Jobs orphan their pods unless told otherwise.
+	jobOpts := opts
+	if jobOpts.PropagationPolicy == nil {
+		background := metav1.DeletePropagationBackground
+		jobOpts.PropagationPolicy = &background
+	}
+
+	if err := r.deleteUntracked(ctx, jobOpts); err != nil {
+		return err
+	}
+
+	for _, cronJob := range r.CronJobs {
+		if err := deleteResource(ctx, cronJob.Name, "cronjob",
+			r.TestClients.ClientSet.BatchV1().CronJobs(r.namespace()).Delete, jobOpts); err != nil {
+			return err
+		}
+	}
+
+	for _, job := range r.Jobs {
+		if err := deleteResource(ctx, job.Name, "job",
+			r.TestClients.ClientSet.BatchV1().Jobs(r.namespace()).Delete, jobOpts); err != nil {
+			return err
+		}
+	}
+
 	for _, statefulSet := range r.StatefulSets {
-		if err := deleteResource(*r.Ctx, statefulSet.Name, "statefulset",
-			r.TestClients.ClientSet.AppsV1().StatefulSets("default").Delete); err != nil {
+		if err := deleteResource(ctx, statefulSet.Name, "statefulset",
+			r.TestClients.ClientSet.AppsV1().StatefulSets(r.namespace()).Delete, opts); err != nil {
+			return err
+		}
+	}
+
+	for _, daemonSet := range r.DaemonSets {
+		if err := deleteResource(ctx, daemonSet.Name, "daemonset",
+			r.TestClients.ClientSet.AppsV1().DaemonSets(r.namespace()).Delete, opts); err != nil {
 			return err
 		}
 	}
 
 	for _, deployment := range r.Deployments {
-		if err := deleteResource(*r.Ctx, deployment.Name, "deployment",
-			r.TestClients.ClientSet.AppsV1().Deployments("default").Delete); err != nil {
+		if err := deleteResource(ctx, deployment.Name, "deployment",
+			r.TestClients.ClientSet.AppsV1().Deployments(r.namespace()).Delete, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := r.deleteObjects(ctx, opts); err != nil {
+		return err
+	}
+
+	for _, service := range r.Services {
+		if err := deleteResource(ctx, service.Name, "service",
+			r.TestClients.ClientSet.CoreV1().Services(r.namespace()).Delete, opts); err != nil {
 			return err
 		}
 	}
 
 	for _, secret := range r.Secrets {
-		if err := deleteResource(*r.Ctx, secret.Name, "secret",
-			r.TestClients.ClientSet.CoreV1().Secrets("default").Delete); err != nil {
+		if err := deleteResource(ctx, secret.Name, "secret",
+			r.TestClients.ClientSet.CoreV1().Secrets(r.namespace()).Delete, opts); err != nil {
 			return err
 		}
 	}
 
 	for _, configMap := range r.ConfigMaps {
-		if err := deleteResource(*r.Ctx, configMap.Name, "configmap",
-			r.TestClients.ClientSet.CoreV1().ConfigMaps("default").Delete); err != nil {
+		if err := deleteResource(ctx, configMap.Name, "configmap",
+			r.TestClients.ClientSet.CoreV1().ConfigMaps(r.namespace()).Delete, opts); err != nil {
 			return err
 		}
 	}
@@ -246,7 +405,17 @@ func (r *Resources) Delete() error {
 	return nil
 }
 
+// Update writes obj to the cluster. Objects that are not yet tracked by r are
+// remembered so that the automatic cleanup deletes them as well. In server-side
+// apply mode, obj is applied, so it does not need a current resourceVersion.
 func (r *Resources) Update(obj client.Object) (*Resources, error) {
+	r.registerCleanup()
+	r.track(obj)
+
+	if r.ServerSideApply {
+		return r, r.apply(*r.Ctx, obj)
+	}
+
 	err := r.TestClients.K8sClient.Update(*r.Ctx, obj)
 
 	return r, err
@@ -256,11 +425,11 @@ func (r *Resources) WithSecret(name string) *Resources {
 	r.Secrets = append(r.Secrets, &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
-			APIVersion: "appsv1",
+			APIVersion: "v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
-			Namespace: "default",
+			Namespace: r.namespace(),
 		},
 		Data: map[string][]byte{
 			"key": []byte("value"),
@@ -280,7 +449,7 @@ func (r *Resources) WithConfigMap(name string) *Resources {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
-			Namespace: "default",
+			Namespace: r.namespace(),
 		},
 		Data: map[string]string{
 			"key": "value",
@@ -302,11 +471,11 @@ func (r *Resources) WithDeployment(name string) *Deployment {
 	r.Deployments = append(r.Deployments, &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Deployment",
-			APIVersion: "apps/appsv1",
+			APIVersion: "apps/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
-			Namespace: "default",
+			Namespace: r.namespace(),
 			Labels: map[string]string{
 				"app": name,
 			},
@@ -326,15 +495,17 @@ func (r *Resources) WithDeployment(name string) *Deployment {
 	return &Deployment{*r}
 }
 
+// WithStatefulSet adds a StatefulSet together with its governing headless
+// Service, see StatefulSet.WithoutHeadlessService to opt out.
 func (r *Resources) WithStatefulSet(name string) *StatefulSet {
 	r.StatefulSets = append(r.StatefulSets, &appsv1.StatefulSet{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "StatefulSet",
-			APIVersion: "apps/appsv1",
+			APIVersion: "apps/v1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
-			Namespace: "default",
+			Namespace: r.namespace(),
 			Labels: map[string]string{
 				"app": name,
 			},
@@ -350,7 +521,10 @@ func (r *Resources) WithStatefulSet(name string) *StatefulSet {
 		},
 	})
 
-	r.ApplyOptions(r.StatefulSets[len(r.StatefulSets)-1])
+	statefulSet := r.StatefulSets[len(r.StatefulSets)-1]
+	r.ApplyOptions(statefulSet)
+
+	r.withGoverningService(statefulSet)
 
 	return &StatefulSet{*r}
 }
@@ -417,27 +591,52 @@ func (r *Resources) GetResources() *Resources {
 	return r
 }
 
+// namespace returns the namespace all resources are created in, falling back
+// to DefaultNamespace for Resources that were not constructed via New.
+func (r *Resources) namespace() string {
+	if r.Namespace == "" {
+		return DefaultNamespace
+	}
+
+	return r.Namespace
+}
+
+// TestClients bundles the typed clientset and the controller-runtime client
+// used by Resources. Both are interfaces, so fakes can be plugged in.
 type TestClients struct {
-	ClientSet *kubernetes.Clientset
+	ClientSet kubernetes.Interface
 	K8sClient client.Client
 }
 
+// NewTestClients creates TestClients from caller-provided clients.
+func NewTestClients(clientSet kubernetes.Interface, k8sClient client.Client) *TestClients {
+	return &TestClients{
+		ClientSet: clientSet,
+		K8sClient: k8sClient,
+	}
+}
+
+// NewTestClientsForConfig creates TestClients for the given REST config.
+func NewTestClientsForConfig(cfg *rest.Config) (*TestClients, error) {
+	clientSet, k8sClient, err := k8sinternal.BuildClientsForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTestClients(clientSet, k8sClient), nil
+}
+
+// SetupTestClients creates TestClients for the current kubeconfig context and
+// fails the test if that is not possible.
 func SetupTestClients(t *testing.T) *TestClients {
 	clientSet, k8sClient, err := k8sinternal.BuildClients()
 	if err != nil {
 		t.Fatalf("Failed to set up Kubernetes clients: %v", err)
 	}
 
-	return &TestClients{
-		ClientSet: clientSet,
-		K8sClient: k8sClient,
-	}
+	return NewTestClients(clientSet, k8sClient)
 }
 
 func SetupScheme() *runtime.Scheme {
-	scheme := runtime.NewScheme()
-	_ = appsv1.AddToScheme(scheme)
-	_ = corev1.AddToScheme(scheme)
-
-	return scheme
+	return k8sinternal.NewScheme()
 }
@@ -2,7 +2,7 @@ package k8stest
 
 import (
 	"context"
-	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -232,72 +232,52 @@ func TestDeploymentWithInvalidImage(t *testing.T) {
 		WithDeployment("deployment-with-invalid-image").
 		Create()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	waitForResourcesCh := make(chan error, 1)
-	go func() {
-		waitForResourcesCh <- resources.Wait(5 * time.Second)
-	}()
-
-	podStateCh := make(chan bool, 1)
-	errorCh := make(chan error, 1)
+	_, err = resources.WaitForContainerState(resources.Deployments[0], "ErrImagePull", 10*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
 
-	go func() {
-		found, err := checkPodForErrImagePull(resources.TestClients, 10*time.Second)
-		if err != nil {
-			errorCh <- err
-		} else if found {
-			podStateCh <- true
-		}
-	}()
+	err = resources.Delete()
+	if err != nil {
+		t.Error(err)
+	}
+}
 
-	select {
-	case <-podStateCh:
-		// Pod has reached ErrImagePull state as expected
-		break
-	case pollError := <-errorCh:
-		t.Errorf("Error checking pod for state: %v", pollError)
+func TestDeploymentGracefulTermination(t *testing.T) {
+	resources, err := New(t, context.Background()).
+		WithDeployment("deployment-graceful-termination").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		break
-	case waitError := <-waitForResourcesCh:
-		if waitError != nil {
-			t.Errorf("Unexpected error from Wait: %v", waitError)
+	err = resources.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
 
-			break
-		}
-		// Wait should not finish before the Pod has reached ErrImagePull => Error
-		t.Error("Expected pod to reach ErrImagePull before Wait finished")
+	recorder, err := resources.RecordTerminations(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	err = resources.Delete()
+	err = resources.DeleteAndWait()
 	if err != nil {
 		t.Error(err)
 	}
-}
 
-func checkPodForErrImagePull(testClients *TestClients, timeout time.Duration) (bool, error) {
-	for start := time.Now(); time.Since(start) < timeout; {
-		podList, err := testClients.ClientSet.CoreV1().Pods("default").List(
-			context.Background(),
-			metav1.ListOptions{
-				LabelSelector: "app=deployment-with-invalid-image",
-			},
-		)
-
-		if err != nil || len(podList.Items) == 0 || len(podList.Items[0].Status.ContainerStatuses) == 0 {
-			continue
-		}
+	terminations := recorder.Stop()
 
-		cs := podList.Items[0].Status.ContainerStatuses
-		if cs[0].State.Waiting != nil && cs[0].State.Waiting.Reason == "ErrImagePull" {
-			return true, nil
-		}
+	AssertGracefulTermination(t, terminations)
 
-		time.Sleep(100 * time.Millisecond)
+	for _, termination := range terminations {
+		if !strings.Contains(termination.Message, "Terminated by SIGTERM") {
+			t.Errorf("Expected the termination log to be written on SIGTERM, got %s", termination)
+		}
 	}
-	//nolint:err113 // linter rule reasons do not really apply here (no comparison, memory allocation negligible)
-	return false, errors.New("timeout waiting for pod to reach ErrImagePull")
 }
 
 func TestConfigurableTimeout(t *testing.T) {
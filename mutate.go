@@ -0,0 +1,108 @@
+package k8stest
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Mutate reads the latest version of obj from the cluster, applies mutate to
+// it and writes it back. When the write conflicts because someone else, e.g. a
+// controller, changed the object in between, it starts over with backoff.
+// Afterwards obj holds the stored state. Like with Update, objects that are not
+// yet tracked by r are deleted together with the other resources.
+func (r *Resources) Mutate(obj client.Object, mutate func(obj client.Object) error) error {
+	r.registerCleanup()
+	r.track(obj)
+
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current, ok := obj.DeepCopyObject().(client.Object)
+		if !ok {
+			return nil
+		}
+
+		if err := r.TestClients.K8sClient.Get(*r.Ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+			return err
+		}
+
+		if err := mutate(current); err != nil {
+			return err
+		}
+
+		if err := r.TestClients.K8sClient.Update(*r.Ctx, current); err != nil {
+			return err
+		}
+
+		writeBack(obj, current)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mutate %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	return r.syncApplied(obj)
+}
+
+// MutateDeployment works like Mutate for the Deployment with the given name and
+// keeps the Deployment in r.Deployments in sync.
+func (r *Resources) MutateDeployment(name string, mutate func(*appsv1.Deployment)) (*appsv1.Deployment, error) {
+	return mutateNamed(r, r.Deployments, name, mutate)
+}
+
+// MutateStatefulSet works like Mutate for the StatefulSet with the given name
+// and keeps the StatefulSet in r.StatefulSets in sync.
+func (r *Resources) MutateStatefulSet(name string, mutate func(*appsv1.StatefulSet)) (*appsv1.StatefulSet, error) {
+	return mutateNamed(r, r.StatefulSets, name, mutate)
+}
+
+// MutateConfigMap works like Mutate for the ConfigMap with the given name and
+// keeps the ConfigMap in r.ConfigMaps in sync.
+func (r *Resources) MutateConfigMap(name string, mutate func(*corev1.ConfigMap)) (*corev1.ConfigMap, error) {
+	return mutateNamed(r, r.ConfigMaps, name, mutate)
+}
+
+// MutateSecret works like Mutate for the Secret with the given name and keeps
+// the Secret in r.Secrets in sync.
+func (r *Resources) MutateSecret(name string, mutate func(*corev1.Secret)) (*corev1.Secret, error) {
+	return mutateNamed(r, r.Secrets, name, mutate)
+}
+
+// mutateNamed mutates the object with the given name from objects in place, or
+// a new one in the namespace of r if objects does not contain it.
+func mutateNamed[T any, P interface {
+	*T
+	client.Object
+}](r *Resources, objects []P, name string, mutate func(P)) (P, error) {
+	obj := findNamed(objects, name)
+	if obj == nil {
+		obj = P(new(T))
+		obj.SetName(name)
+		obj.SetNamespace(r.namespace())
+	}
+
+	err := r.Mutate(obj, func(current client.Object) error {
+		if typed, ok := current.(P); ok {
+			mutate(typed)
+		}
+
+		return nil
+	})
+
+	return obj, err
+}
+
+func findNamed[T client.Object](objects []T, name string) T {
+	for _, obj := range objects {
+		if obj.GetName() == name {
+			return obj
+		}
+	}
+
+	var none T
+
+	return none
+}
@@ -0,0 +1,111 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestMutateDeployment(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithDeployment("deployment-mutate-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment, err := resources.MutateDeployment("deployment-mutate-1", func(deployment *appsv1.Deployment) {
+		deployment.Spec.Replicas = int32Ptr(2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deployment != resources.Deployments[0] {
+		t.Error("Expected MutateDeployment to return the deployment of the resources")
+	}
+
+	if *resources.Deployments[0].Spec.Replicas != 2 {
+		t.Errorf("Expected the local deployment to have 2 replicas, got %d", *resources.Deployments[0].Spec.Replicas)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := resources.TestClients.ClientSet.AppsV1().Deployments(resources.Namespace).Get(
+		context.Background(), "deployment-mutate-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Status.AvailableReplicas != 2 {
+		t.Errorf("Expected 2 available replicas, got %d", stored.Status.AvailableReplicas)
+	}
+}
+
+func TestMutateRetriesOnConflict(t *testing.T) {
+	testClients := NewFakeTestClients()
+
+	k8sClient, ok := testClients.K8sClient.(client.WithWatch)
+	if !ok {
+		t.Fatal("Expected the fake client to support watches")
+	}
+
+	conflicts := 2
+	testClients.K8sClient = interceptor.NewClient(k8sClient, interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if conflicts > 0 {
+				conflicts--
+
+				return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), nil)
+			}
+
+			return c.Update(ctx, obj, opts...)
+		},
+	})
+
+	resources, err := NewWithClients(t, context.Background(), testClients).
+		WithConfigMap("config-map-mutate-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+
+	_, err = resources.MutateConfigMap("config-map-mutate-1", func(configMap *corev1.ConfigMap) {
+		calls++
+		configMap.Data["key"] = "value"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected the mutation to be applied 3 times, got %d", calls)
+	}
+
+	if resources.ConfigMaps[0].Data["key"] != "value" {
+		t.Errorf("Expected the local configmap to be in sync, got %v", resources.ConfigMaps[0].Data)
+	}
+}
+
+func TestMutateNotFound(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	_, err := resources.MutateConfigMap("config-map-mutate-missing", func(*corev1.ConfigMap) {})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected a NotFound error, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package k8stest
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const namespaceSuffixLength = 5
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// WithTestNamespace returns an Option that creates a uniquely named namespace
+// for the test, derived from t.Name(). All builders and client calls of the
+// Resources object target that namespace, and the namespace is deleted again
+// through t.Cleanup when the test finishes.
+func WithTestNamespace() Option {
+	return func(t *testing.T, r *Resources) {
+		t.Helper()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespaceNameForTest(t.Name()),
+			},
+		}
+
+		_, err := r.TestClients.ClientSet.CoreV1().Namespaces().Create(*r.Ctx, namespace, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("Failed to create test namespace %s: %v", namespace.Name, err)
+		}
+
+		r.Namespace = namespace.Name
+		cleanupCtx := context.WithoutCancel(*r.Ctx)
+
+		t.Cleanup(func() {
+			if !r.shouldCleanup(t) {
+				t.Logf("Keeping test namespace %s for inspection", namespace.Name)
+
+				return
+			}
+
+			err := r.TestClients.ClientSet.CoreV1().Namespaces().Delete(
+				cleanupCtx, namespace.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				t.Errorf("Failed to delete test namespace %s: %v", namespace.Name, err)
+			}
+		})
+	}
+}
+
+// namespaceNameForTest turns a test name such as "TestFoo/sub_test" into a valid
+// DNS-1123 label like "testfoo-sub-test-x7k2p". The random suffix keeps parallel
+// runs and repeated subtests apart.
+func namespaceNameForTest(testName string) string {
+	prefix := invalidNamespaceChars.ReplaceAllString(strings.ToLower(testName), "-")
+
+	maxPrefixLength := validation.DNS1123LabelMaxLength - namespaceSuffixLength - 1
+	if len(prefix) > maxPrefixLength {
+		prefix = prefix[:maxPrefixLength]
+	}
+
+	prefix = strings.Trim(prefix, "-")
+	if prefix == "" {
+		prefix = "k8stest"
+	}
+
+	return prefix + "-" + utilrand.String(namespaceSuffixLength)
+}
@@ -0,0 +1,96 @@
+package k8stest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestNamespaceNameForTest(t *testing.T) {
+	tests := []struct {
+		name           string
+		testName       string
+		expectedPrefix string
+	}{
+		{
+			name:           "Simple test name",
+			testName:       "TestFluent",
+			expectedPrefix: "testfluent-",
+		},
+		{
+			name:           "Subtest name",
+			testName:       "TestConfigurableTimeout/Timeout_1_second",
+			expectedPrefix: "testconfigurabletimeout-timeout-1-second-",
+		},
+		{
+			name:           "Only invalid characters",
+			testName:       "___",
+			expectedPrefix: "k8stest-",
+		},
+		{
+			name:           "Very long name",
+			testName:       "Test" + strings.Repeat("VeryLong", 10),
+			expectedPrefix: "testverylong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := namespaceNameForTest(tt.testName)
+
+			if !strings.HasPrefix(namespace, tt.expectedPrefix) {
+				t.Errorf("Expected namespace %q to start with %q", namespace, tt.expectedPrefix)
+			}
+
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				t.Errorf("Expected %q to be a valid namespace name: %v", namespace, errs)
+			}
+		})
+	}
+}
+
+func TestNamespaceNameForTestIsUnique(t *testing.T) {
+	first := namespaceNameForTest(t.Name())
+	second := namespaceNameForTest(t.Name())
+
+	if first == second {
+		t.Errorf("Expected unique namespace names, got %q twice", first)
+	}
+}
+
+func TestWithTestNamespace(t *testing.T) {
+	resources, err := New(t, context.Background(), WithTestNamespace()).
+		WithResourceOption(ZeroTerminationGracePeriodOption()).
+		WithDeployment("deployment-namespaced").
+		WithConfigMap("config-map-namespaced").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resources.Namespace == DefaultNamespace {
+		t.Fatalf("Expected a per-test namespace, got %q", resources.Namespace)
+	}
+
+	if resources.Deployments[0].Namespace != resources.Namespace {
+		t.Errorf("Expected deployment in namespace %q, got %q",
+			resources.Namespace, resources.Deployments[0].Namespace)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(),
+		"config-map-namespaced",
+		metav1.GetOptions{},
+	)
+	if err != nil {
+		t.Errorf("Failed to get configmap from test namespace: %v", err)
+	}
+
+	err = resources.Wait()
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,242 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrInvalidReadinessExpression is returned by checks created with ReadyWhen
+// whose expression cannot be parsed.
+var ErrInvalidReadinessExpression = errors.New("invalid readiness expression")
+
+// ReadinessCheck reports whether an object, as currently stored in the
+// cluster, is ready. It receives the object in its unstructured form so that
+// checks work for custom resources without Go types.
+type ReadinessCheck func(obj *unstructured.Unstructured) (bool, error)
+
+// TrackedObject is an arbitrary object managed through the controller-runtime
+// client, e.g. a custom resource, together with the checks Wait uses for it.
+// An object without checks is ready as soon as it exists.
+type TrackedObject struct {
+	Object          client.Object
+	ReadinessChecks []ReadinessCheck
+}
+
+// WithObject adds an arbitrary typed object. It is created, updated and deleted
+// through the controller-runtime client, so its type must be registered in the
+// scheme of the client. Namespaced objects without a namespace are placed in
+// the namespace of the Resources object.
+func (r *Resources) WithObject(obj client.Object, checks ...ReadinessCheck) *Resources {
+	if obj.GetNamespace() == "" && r.isNamespaced(obj) {
+		obj.SetNamespace(r.namespace())
+	}
+
+	r.Objects = append(r.Objects, &TrackedObject{
+		Object:          obj,
+		ReadinessChecks: checks,
+	})
+
+	r.ApplyOptions(obj)
+
+	return r
+}
+
+// WithUnstructured adds an object given in unstructured form, e.g. a custom
+// resource whose Go type is not available. Its apiVersion and kind must be set.
+func (r *Resources) WithUnstructured(obj *unstructured.Unstructured, checks ...ReadinessCheck) *Resources {
+	return r.WithObject(obj, checks...)
+}
+
+// ConditionIs returns a ReadinessCheck that is satisfied when the object has a
+// status condition of the given type with the given status, e.g.
+// ConditionIs("Ready", "True").
+func ConditionIs(conditionType, status string) ReadinessCheck {
+	return ReadyWhen(fmt.Sprintf("status.conditions[type=%s].status==%s", conditionType, status))
+}
+
+// ReadyWhen returns a ReadinessCheck for a simple field expression of the form
+// "<path>==<value>". The path is a dot-separated list of fields; a field may
+// select a list element by index, as in "containers[0]", or by the value of
+// one of its fields, as in "conditions[type=Ready]". The short form
+// "status.conditions[type=Ready]==True" compares the status of the condition.
+func ReadyWhen(expression string) ReadinessCheck {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		path, expected, found := strings.Cut(expression, "==")
+		if !found {
+			return false, fmt.Errorf("%w: %q has no ==", ErrInvalidReadinessExpression, expression)
+		}
+
+		path = strings.TrimSpace(path)
+		if strings.HasSuffix(path, "]") {
+			open := strings.LastIndex(path, "[")
+			if open < 0 {
+				return false, fmt.Errorf("%w: %q has ] without [", ErrInvalidReadinessExpression, expression)
+			}
+
+			if strings.Contains(path[open:], "=") {
+				path += ".status"
+			}
+		}
+
+		value, exists, err := lookupField(obj.Object, path)
+		if err != nil || !exists {
+			return false, err
+		}
+
+		return fmt.Sprint(value) == strings.TrimSpace(expected), nil
+	}
+}
+
+// lookupField resolves a path as accepted by ReadyWhen in content.
+func lookupField(content map[string]any, path string) (any, bool, error) {
+	var current any = content
+
+	for segment := range strings.SplitSeq(path, ".") {
+		field, selector, hasSelector := strings.Cut(segment, "[")
+
+		fields, ok := current.(map[string]any)
+		if !ok {
+			return nil, false, nil
+		}
+
+		if current, ok = fields[field]; !ok {
+			return nil, false, nil
+		}
+
+		if !hasSelector {
+			continue
+		}
+
+		selector, ok = strings.CutSuffix(selector, "]")
+		if !ok {
+			return nil, false, fmt.Errorf("%w: unterminated selector in %q", ErrInvalidReadinessExpression, segment)
+		}
+
+		items, ok := current.([]any)
+		if !ok {
+			return nil, false, nil
+		}
+
+		if current, ok = selectItem(items, selector); !ok {
+			return nil, false, nil
+		}
+	}
+
+	return current, true, nil
+}
+
+// selectItem returns the list item addressed by an index such as "0" or by a
+// field match such as "type=Ready".
+func selectItem(items []any, selector string) (any, bool) {
+	key, value, byField := strings.Cut(selector, "=")
+	if !byField {
+		index, err := strconv.Atoi(selector)
+		if err != nil || index < 0 || index >= len(items) {
+			return nil, false
+		}
+
+		return items[index], true
+	}
+
+	for _, item := range items {
+		fields, ok := item.(map[string]any)
+		if ok && fmt.Sprint(fields[key]) == value {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+// isNamespaced reports whether obj is namespaced. Objects the client does not
+// know, e.g. custom resources whose CRD is not installed yet, are assumed to be.
+func (r *Resources) isNamespaced(obj runtime.Object) bool {
+	if r.TestClients == nil || r.TestClients.K8sClient == nil {
+		return true
+	}
+
+	namespaced, err := r.TestClients.K8sClient.IsObjectNamespaced(obj)
+
+	return err != nil || namespaced
+}
+
+// getUnstructured reads the current state of obj from the cluster.
+func (r *Resources) getUnstructured(ctx context.Context, obj client.Object) (*unstructured.Unstructured, error) {
+	gvk, err := r.TestClients.K8sClient.GroupVersionKindFor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(gvk)
+
+	err = r.TestClients.K8sClient.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// passesReadinessChecks evaluates the readiness checks for the current state
+// of an object.
+func passesReadinessChecks(current *unstructured.Unstructured, checks []ReadinessCheck) (bool, error) {
+	for _, check := range checks {
+		ready, err := check(current)
+		if err != nil || !ready {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (r *Resources) createObjects() error {
+	for _, tracked := range r.Objects {
+		obj := createCopy(tracked.Object)
+
+		if err := r.TestClients.K8sClient.Create(*r.Ctx, obj); err != nil {
+			return fmt.Errorf("failed to create %s %s: %w", objectKind(obj), obj.GetName(), err)
+		}
+
+		writeBack(tracked.Object, obj)
+	}
+
+	return nil
+}
+
+func (r *Resources) deleteObjects(ctx context.Context, opts metav1.DeleteOptions) error {
+	for _, tracked := range r.Objects {
+		obj, ok := tracked.Object.DeepCopyObject().(client.Object)
+		if !ok {
+			continue
+		}
+
+		err := r.TestClients.K8sClient.Delete(ctx, obj, &client.DeleteOptions{Raw: &opts})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete %s %s: %w", objectKind(obj), obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Resources) appendRemainingObjects(ctx context.Context, remaining []StuckObject) ([]StuckObject, error) {
+	for _, tracked := range r.Objects {
+		current, err := r.getUnstructured(ctx, tracked.Object)
+		if remaining, err = appendIfExists(remaining, objectKind(tracked.Object), current, err); err != nil {
+			return nil, err
+		}
+	}
+
+	return remaining, nil
+}
@@ -0,0 +1,153 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestReadyWhen(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "app"}},
+		},
+		"status": map[string]any{
+			"phase":         "Running",
+			"readyReplicas": int64(3),
+			"conditions": []any{
+				map[string]any{"type": "Progressing", "status": "True"},
+				map[string]any{"type": "Ready", "status": "False"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		check    ReadinessCheck
+		expected bool
+	}{
+		{
+			name:     "Field",
+			check:    ReadyWhen("status.phase==Running"),
+			expected: true,
+		},
+		{
+			name:     "Number",
+			check:    ReadyWhen("status.readyReplicas == 3"),
+			expected: true,
+		},
+		{
+			name:     "List index",
+			check:    ReadyWhen("spec.containers[0].name==app"),
+			expected: true,
+		},
+		{
+			name:     "Condition short form",
+			check:    ReadyWhen("status.conditions[type=Progressing]==True"),
+			expected: true,
+		},
+		{
+			name:     "Condition not met",
+			check:    ConditionIs("Ready", "True"),
+			expected: false,
+		},
+		{
+			name:     "Missing field",
+			check:    ReadyWhen("status.observedGeneration==1"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, err := tt.check(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ready != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, ready)
+			}
+		})
+	}
+
+	for _, expression := range []string{"status.phase", "status.foo]==x"} {
+		_, err := ReadyWhen(expression)(obj)
+		if !errors.Is(err, ErrInvalidReadinessExpression) {
+			t.Errorf("Expected ErrInvalidReadinessExpression for %q, got %v", expression, err)
+		}
+	}
+}
+
+func TestWithObject(t *testing.T) {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "service-account-object-1",
+		},
+	}
+
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("config-map-object-1")
+	configMap.Object["data"] = map[string]any{"ready": "true"}
+
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithObject(serviceAccount).
+		WithUnstructured(configMap, ReadyWhen("data.ready==true")).
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if serviceAccount.Namespace != resources.Namespace {
+		t.Errorf("Expected object in namespace %q, got %q", resources.Namespace, serviceAccount.Namespace)
+	}
+
+	err = resources.Wait(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-object-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected unstructured configmap to be created: %v", err)
+	}
+
+	err = resources.DeleteAndWait(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().ServiceAccounts(resources.Namespace).Get(
+		context.Background(), "service-account-object-1", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected service account to be deleted, got %v", err)
+	}
+}
+
+func TestWithObjectNotReady(t *testing.T) {
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("config-map-object-2")
+
+	resources, err := NewFake(t, context.Background()).
+		WithUnstructured(configMap, ConditionIs("Ready", "True")).
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(200 * time.Millisecond)
+	if err == nil {
+		t.Error("Expected Wait to time out for an object that never becomes ready")
+	}
+}
@@ -0,0 +1,74 @@
+package k8stest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Patch sends data as a patch of the given type, e.g. types.StrategicMergePatchType,
+// types.MergePatchType or types.JSONPatchType, for obj and writes the patched
+// object back into obj. Unlike Update, only the fields in data are changed, so
+// Patch does not race with other writers. Objects that are not yet tracked by r
+// are deleted together with the other resources.
+func (r *Resources) Patch(obj client.Object, patchType types.PatchType, data []byte) (*Resources, error) {
+	r.registerCleanup()
+	r.track(obj)
+
+	err := r.TestClients.K8sClient.Patch(*r.Ctx, obj, client.RawPatch(patchType, data))
+	if err != nil {
+		return r, fmt.Errorf("failed to patch %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	return r, r.syncApplied(obj)
+}
+
+// PatchConfigMapData sets the given keys of the data of the ConfigMap with the
+// given name through a JSON merge patch, leaving all other keys alone. The
+// ConfigMap in r.ConfigMaps is kept in sync.
+func (r *Resources) PatchConfigMapData(name string, data map[string]string) (*Resources, error) {
+	patch, err := json.Marshal(map[string]any{"data": data})
+	if err != nil {
+		return r, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	return patchNamed(r, r.ConfigMaps, name, types.MergePatchType, patch)
+}
+
+// PatchDeploymentImage sets the image of a container of the Deployment with the
+// given name through a strategic merge patch, which starts a rollout. The
+// Deployment in r.Deployments is kept in sync.
+func (r *Resources) PatchDeploymentImage(name, container, image string) (*Resources, error) {
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"template": map[string]any{
+				"spec": map[string]any{
+					"containers": []map[string]string{{"name": container, "image": image}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return r, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	return patchNamed(r, r.Deployments, name, types.StrategicMergePatchType, patch)
+}
+
+// patchNamed patches the object with the given name from objects in place, or
+// a new one in the namespace of r if objects does not contain it.
+func patchNamed[T any, P interface {
+	*T
+	client.Object
+}](r *Resources, objects []P, name string, patchType types.PatchType, data []byte) (*Resources, error) {
+	obj := findNamed(objects, name)
+	if obj == nil {
+		obj = P(new(T))
+		obj.SetName(name)
+		obj.SetNamespace(r.namespace())
+	}
+
+	return r.Patch(obj, patchType, data)
+}
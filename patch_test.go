@@ -0,0 +1,111 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPatchConfigMapData(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace()).
+		WithConfigMap("config-map-patch-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.PatchConfigMapData("config-map-patch-1", map[string]string{"reload": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configMap, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-patch-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.Data["reload"] != "1" || configMap.Data["key"] != "value" {
+		t.Errorf("Expected the patched key to be added and the existing one kept, got %v", configMap.Data)
+	}
+
+	if resources.ConfigMaps[0].Data["reload"] != "1" {
+		t.Errorf("Expected the local configmap to be in sync, got %v", resources.ConfigMaps[0].Data)
+	}
+}
+
+func TestPatchDeploymentImage(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithDeployment("deployment-patch-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.PatchDeploymentImage("deployment-patch-1", "noop-container", "busybox:stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := resources.Deployments[0]
+	container := deployment.Spec.Template.Spec.Containers[0]
+
+	if container.Image != "busybox:stable" {
+		t.Errorf("Expected the local deployment to have the patched image, got %s", container.Image)
+	}
+
+	if len(container.Command) == 0 {
+		t.Error("Expected the strategic merge patch to keep the command of the container")
+	}
+
+	if deployment.Generation != 2 {
+		t.Errorf("Expected the patch to bump the generation to 2, got %d", deployment.Generation)
+	}
+}
+
+func TestPatchTypes(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace()).
+		WithConfigMap("config-map-patch-2").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configMap := resources.ConfigMaps[0]
+
+	_, err = resources.Patch(configMap, types.JSONPatchType,
+		[]byte(`[{"op": "replace", "path": "/data/key", "value": "json-patch"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.Data["key"] != "json-patch" {
+		t.Errorf("Expected the JSON patch to replace the value, got %v", configMap.Data)
+	}
+
+	_, err = resources.Patch(configMap, types.StrategicMergePatchType,
+		[]byte(`{"metadata": {"labels": {"patched": "true"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configMap.Labels["patched"] != "true" || configMap.Data["key"] != "json-patch" {
+		t.Errorf("Expected the strategic merge patch to add a label only, got %v and %v",
+			configMap.Labels, configMap.Data)
+	}
+
+	_, err = resources.Patch(configMap, types.JSONPatchType,
+		[]byte(`[{"op": "test", "path": "/data/key", "value": "other"}]`))
+	if err == nil {
+		t.Error("Expected a failing JSON patch test operation to return an error")
+	}
+}
@@ -0,0 +1,193 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrNotControlled is returned by PodByOrdinal when the pod with the requested
+// name exists but does not belong to the StatefulSet.
+var ErrNotControlled = errors.New("pod is not controlled by the statefulset")
+
+// Pods returns the pods of workload, which is a Deployment, StatefulSet,
+// DaemonSet or Job, sorted by name. Pods are selected by the selector of the
+// workload as stored on the server and must be controlled by it, or for a
+// Deployment by one of its ReplicaSets, so pods of other workloads with
+// matching labels are left out. Terminating pods are included.
+func (r *Resources) Pods(workload client.Object) ([]corev1.Pod, error) {
+	live, err := liveWorkload(*r.Ctx, r, workload)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.listPods(*r.Ctx, live)
+}
+
+// ReadyPods returns the pods of workload that are ready and not terminating.
+func (r *Resources) ReadyPods(workload client.Object) ([]corev1.Pod, error) {
+	pods, err := r.Pods(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	var ready []corev1.Pod
+
+	for i := range pods {
+		if pods[i].DeletionTimestamp == nil && isPodReady(&pods[i]) {
+			ready = append(ready, pods[i])
+		}
+	}
+
+	return ready, nil
+}
+
+// PodByOrdinal returns the pod with the given ordinal of statefulSet, e.g. web-0
+// for ordinal 0 of the StatefulSet web.
+func (r *Resources) PodByOrdinal(statefulSet *appsv1.StatefulSet, ordinal int) (*corev1.Pod, error) {
+	live, err := liveWorkload(*r.Ctx, r, statefulSet)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s-%d", live.GetName(), ordinal)
+
+	pod, err := r.TestClients.ClientSet.CoreV1().Pods(live.GetNamespace()).Get(*r.Ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s: %w", name, err)
+	}
+
+	if !isControlledBy(pod, "StatefulSet", live) {
+		return nil, fmt.Errorf("failed to get pod %s: %w", name, ErrNotControlled)
+	}
+
+	return pod, nil
+}
+
+// ReplicaSets returns the ReplicaSets of deployment, sorted by name. They are
+// selected by the selector of the Deployment and must be controlled by it.
+func (r *Resources) ReplicaSets(deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	live, err := liveWorkload(*r.Ctx, r, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.listReplicaSets(*r.Ctx, live)
+}
+
+// liveWorkload reads the current state of workload, so that its UID and
+// selector are the ones stored on the server.
+func liveWorkload[T client.Object](ctx context.Context, r *Resources, workload T) (T, error) {
+	live, ok := workload.DeepCopyObject().(T)
+	if !ok {
+		return workload, nil
+	}
+
+	key := client.ObjectKeyFromObject(workload)
+	if key.Namespace == "" {
+		key.Namespace = r.namespace()
+	}
+
+	if err := r.TestClients.K8sClient.Get(ctx, key, live); err != nil {
+		return live, fmt.Errorf("failed to get %s %s: %w", workloadKind(workload), workload.GetName(), err)
+	}
+
+	return live, nil
+}
+
+// listPods returns the pods of the live workload, see Pods.
+func (r *Resources) listPods(ctx context.Context, workload client.Object) ([]corev1.Pod, error) {
+	listOptions, ok := selectorListOptions(podSelectorOf(workload))
+	if !ok {
+		return nil, fmt.Errorf("failed to list pods of %s %s: %w", workloadKind(workload), workload.GetName(),
+			ErrNoPodSelector)
+	}
+
+	// Pods of a Deployment are controlled by its ReplicaSets.
+	owners := []metav1.Object{workload}
+	ownerKind := controllerKind(workload)
+
+	if deployment, ok := workload.(*appsv1.Deployment); ok {
+		replicaSets, err := r.listReplicaSets(ctx, deployment)
+		if err != nil {
+			return nil, err
+		}
+
+		owners, ownerKind = nil, "ReplicaSet"
+		for i := range replicaSets {
+			owners = append(owners, &replicaSets[i])
+		}
+	}
+
+	pods, err := r.TestClients.ClientSet.CoreV1().Pods(workload.GetNamespace()).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods of %s %s: %w", workloadKind(workload), workload.GetName(), err)
+	}
+
+	var controlled []corev1.Pod
+
+	for i := range pods.Items {
+		for _, owner := range owners {
+			if isControlledBy(&pods.Items[i], ownerKind, owner) {
+				controlled = append(controlled, pods.Items[i])
+
+				break
+			}
+		}
+	}
+
+	sort.Slice(controlled, func(i, j int) bool {
+		return controlled[i].Name < controlled[j].Name
+	})
+
+	return controlled, nil
+}
+
+// listReplicaSets returns the ReplicaSets of the live deployment, see ReplicaSets.
+func (r *Resources) listReplicaSets(ctx context.Context, deployment *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	listOptions, ok := selectorListOptions(deployment.Spec.Selector)
+	if !ok {
+		return nil, fmt.Errorf("failed to list replicasets of deployment %s: %w", deployment.Name, ErrNoPodSelector)
+	}
+
+	replicaSets, err := r.TestClients.ClientSet.AppsV1().ReplicaSets(deployment.Namespace).List(ctx, listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets of deployment %s: %w", deployment.Name, err)
+	}
+
+	var controlled []appsv1.ReplicaSet
+
+	for i := range replicaSets.Items {
+		if isControlledBy(&replicaSets.Items[i], "Deployment", deployment) {
+			controlled = append(controlled, replicaSets.Items[i])
+		}
+	}
+
+	sort.Slice(controlled, func(i, j int) bool {
+		return controlled[i].Name < controlled[j].Name
+	})
+
+	return controlled, nil
+}
+
+// controllerKind returns the kind the controller references of the pods of
+// workload refer to.
+func controllerKind(workload client.Object) string {
+	switch workload.(type) {
+	case *appsv1.StatefulSet:
+		return "StatefulSet"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
+	case *batchv1.Job:
+		return "Job"
+	default:
+		return workload.GetObjectKind().GroupVersionKind().Kind
+	}
+}
@@ -0,0 +1,226 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// tierLabelOption returns a ResourceOption that selects the pods of
+// Deployments and StatefulSets by a tier label instead of the app label.
+func tierLabelOption(tier string) ResourceOption {
+	return func(obj runtime.Object) {
+		var (
+			selector *metav1.LabelSelector
+			template *corev1.PodTemplateSpec
+		)
+
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			o.Spec.Selector = &metav1.LabelSelector{}
+			selector, template = o.Spec.Selector, &o.Spec.Template
+		case *appsv1.StatefulSet:
+			o.Spec.Selector = &metav1.LabelSelector{}
+			selector, template = o.Spec.Selector, &o.Spec.Template
+		default:
+			return
+		}
+
+		selector.MatchLabels = map[string]string{"tier": tier}
+		template.Labels = map[string]string{"tier": tier}
+	}
+}
+
+func TestPods(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithResourceOption(tierLabelOption("frontend")).
+		WithDeployment("deployment-pods-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.MutateDeployment("deployment-pods-1", func(deployment *appsv1.Deployment) {
+		deployment.Spec.Replicas = int32Ptr(2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A pod with matching labels that does not belong to the deployment.
+	_, err = resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).Create(context.Background(),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "pod-pods-1",
+				Labels: map[string]string{"tier": "frontend"},
+			},
+		}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.Pods(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods) != 2 {
+		t.Fatalf("Expected the 2 pods of the deployment, got %d", len(pods))
+	}
+
+	for _, pod := range pods {
+		if pod.Name == "pod-pods-1" {
+			t.Error("Expected the pod without owner reference to be left out")
+		}
+	}
+
+	ready, err := resources.ReadyPods(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ready) != 2 {
+		t.Errorf("Expected 2 ready pods, got %d", len(ready))
+	}
+}
+
+func TestPodByOrdinal(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithResourceOption(tierLabelOption("backend")).
+		WithStatefulSet("statefulset-pods-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := resources.PodByOrdinal(resources.StatefulSets[0], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pod.Name != "statefulset-pods-1-0" {
+		t.Errorf("Expected pod statefulset-pods-1-0, got %s", pod.Name)
+	}
+
+	_, err = resources.PodByOrdinal(resources.StatefulSets[0], 1)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected a not found error for ordinal 1, got %v", err)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).Create(context.Background(),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "statefulset-pods-1-2"}}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.PodByOrdinal(resources.StatefulSets[0], 2)
+	if !errors.Is(err, ErrNotControlled) {
+		t.Errorf("Expected ErrNotControlled for a pod without owner reference, got %v", err)
+	}
+}
+
+func TestPodsOfJobWithManualSelector(t *testing.T) {
+	manualSelector := func(obj runtime.Object) {
+		if job, ok := obj.(*batchv1.Job); ok {
+			job.Spec.ManualSelector = boolPtr(true)
+			job.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "batch"}}
+			job.Spec.Template.Labels = map[string]string{"tier": "batch"}
+		}
+	}
+
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithResourceOption(manualSelector).
+		WithJob("job-pods-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.Pods(resources.Jobs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods) != 1 || pods[0].Labels["tier"] != "batch" {
+		t.Fatalf("Expected the pod selected by the manual selector, got %v", pods)
+	}
+
+	jobPods, err := resources.JobPods("job-pods-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jobPods) != 1 || jobPods[0].Name != pods[0].Name {
+		t.Errorf("Expected JobPods to return pod %s, got %v", pods[0].Name, jobPods)
+	}
+}
+
+func TestReplicaSets(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithRolloutWait()).
+		WithDeployment("deployment-pods-2").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.PatchDeploymentImage("deployment-pods-2", "noop-container", "busybox:stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replicaSets, err := resources.ReplicaSets(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replicaSets) != 2 {
+		t.Fatalf("Expected the old and the new replicaset, got %d", len(replicaSets))
+	}
+
+	pods, err := resources.Pods(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && pod.Spec.Containers[0].Image != "busybox:stable" {
+			t.Errorf("Expected pod %s to run the new image, got %s", pod.Name, pod.Spec.Containers[0].Image)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrNoPodSelector is returned when the pods of an object that is not a
+// Deployment, StatefulSet, DaemonSet or Job, or has no selector, are requested.
+var ErrNoPodSelector = errors.New("object has no pod selector")
+
+// WaitForContainerState waits until a container of a pod of workload is waiting
+// or terminated with the given reason, e.g. "ErrImagePull", "OOMKilled" or
+// ReasonCrashLoopBackOff, and returns that pod. Init containers are included.
+// Use it to test that a workload fails as expected.
+func (r *Resources) WaitForContainerState(workload client.Object, reason string,
+	timeout ...time.Duration,
+) (*corev1.Pod, error) {
+	return r.waitForPod(workload, func(pod *corev1.Pod) bool {
+		return hasContainerReason(pod, reason)
+	}, timeout...)
+}
+
+// WaitForPodPhase waits until a pod of workload is in the given phase and
+// returns that pod.
+func (r *Resources) WaitForPodPhase(workload client.Object, phase corev1.PodPhase,
+	timeout ...time.Duration,
+) (*corev1.Pod, error) {
+	return r.waitForPod(workload, func(pod *corev1.Pod) bool {
+		return pod.Status.Phase == phase
+	}, timeout...)
+}
+
+// WaitForCrashLoop waits until a container of a pod of workload is in
+// CrashLoopBackOff and returns that pod.
+func (r *Resources) WaitForCrashLoop(workload client.Object, timeout ...time.Duration) (*corev1.Pod, error) {
+	return r.WaitForContainerState(workload, ReasonCrashLoopBackOff, timeout...)
+}
+
+// waitForPod waits until a pod of workload, as returned by Pods, matches and
+// returns it. Terminating pods are ignored.
+func (r *Resources) waitForPod(workload client.Object, matches func(pod *corev1.Pod) bool,
+	timeout ...time.Duration,
+) (*corev1.Pod, error) {
+	applicableTimeout := r.Timeout
+
+	if len(timeout) > 0 {
+		applicableTimeout = timeout[0]
+	}
+
+	var matched *corev1.Pod
+
+	target, source, err := r.podsWaitTarget(workload, func(pods []corev1.Pod) (bool, error) {
+		for i := range pods {
+			if pods[i].DeletionTimestamp == nil && matches(&pods[i]) {
+				matched = &pods[i]
+
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matched, r.waitFor(applicableTimeout, []waitTarget{target}, []waitSource{source})
+}
+
+// podsWaitTarget returns a target that evaluates the pods of workload, as
+// returned by Pods, with ready, and a watch for them.
+func (r *Resources) podsWaitTarget(workload client.Object, ready func(pods []corev1.Pod) (bool, error),
+) (waitTarget, waitSource, error) {
+	// The selector of a Job is generated by the API server, so it is read from
+	// the live object.
+	live, err := liveWorkload(*r.Ctx, r, workload)
+	if err != nil {
+		return waitTarget{}, waitSource{}, err
+	}
+
+	listOptions, ok := selectorListOptions(podSelectorOf(live))
+	if !ok {
+		return waitTarget{}, waitSource{}, fmt.Errorf("failed to wait for pods of %s %s: %w",
+			workloadKind(workload), workload.GetName(), ErrNoPodSelector)
+	}
+
+	namespace := live.GetNamespace()
+
+	pods := r.TestClients.ClientSet.CoreV1().Pods(namespace)
+
+	target := waitTarget{
+		source:    "pod",
+		kind:      workloadKind(workload),
+		namespace: namespace,
+		name:      workload.GetName(),
+		get: func(ctx context.Context) (runtime.Object, error) {
+			live, err := liveWorkload(ctx, r, workload)
+			if err != nil {
+				return nil, err
+			}
+
+			controlled, err := r.listPods(ctx, live)
+			if err != nil {
+				return nil, err
+			}
+
+			return &corev1.PodList{Items: controlled}, nil
+		},
+		ready: func(obj runtime.Object) (bool, error) {
+			list, ok := obj.(*corev1.PodList)
+			if !ok {
+				return false, nil
+			}
+
+			return ready(list.Items)
+		},
+	}
+
+	// Events of single pods cannot be evaluated on their own, so every event
+	// makes the pods be listed again.
+	source := waitSource{
+		key:    "pod",
+		resync: true,
+		watch: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = listOptions.LabelSelector
+
+			return pods.Watch(ctx, opts)
+		},
+	}
+
+	return target, source, nil
+}
+
+// hasContainerReason reports whether a container or init container of pod is
+// waiting or terminated with reason.
+func hasContainerReason(pod *corev1.Pod, reason string) bool {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+		pod.Status.ContainerStatuses...)
+
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == reason {
+			return true
+		}
+
+		if status.State.Terminated != nil && status.State.Terminated.Reason == reason {
+			return true
+		}
+	}
+
+	return false
+}
+
+// workloadKind returns the lower-cased kind of workload for messages, as typed
+// objects returned by the API server have no TypeMeta.
+func workloadKind(workload runtime.Object) string {
+	switch workload.(type) {
+	case *appsv1.Deployment:
+		return "deployment"
+	case *appsv1.StatefulSet:
+		return "statefulset"
+	case *appsv1.DaemonSet:
+		return "daemonset"
+	case *batchv1.Job:
+		return "job"
+	default:
+		return objectKind(workload)
+	}
+}
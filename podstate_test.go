@@ -0,0 +1,105 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWaitForContainerState(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithSimulator(WithImageFailure("busybox:latest", "ErrImagePull"))).
+		WithDeployment("deployment-container-state-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := resources.WaitForContainerState(resources.Deployments[0], "ErrImagePull", 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pod.Labels["app"] != "deployment-container-state-1" {
+		t.Errorf("Expected a pod of the deployment, got %s with labels %v", pod.Name, pod.Labels)
+	}
+}
+
+func TestWaitForCrashLoop(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithSimulator(WithImageFailure("busybox:latest", ReasonCrashLoopBackOff))).
+		WithStatefulSet("statefulset-crash-loop-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := resources.WaitForCrashLoop(resources.StatefulSets[0], 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pod.Name != "statefulset-crash-loop-1-0" {
+		t.Errorf("Expected the first pod of the statefulset, got %s", pod.Name)
+	}
+}
+
+func TestWaitForPodPhase(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithSimulator(WithImageFailure("busybox:latest", ReasonError))).
+		WithJob("job-pod-phase-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := resources.WaitForPodPhase(resources.Jobs[0], corev1.PodFailed, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pod.Status.Phase != corev1.PodFailed {
+		t.Errorf("Expected a failed pod, got %s", pod.Status.Phase)
+	}
+}
+
+func TestWaitForPodPhaseTimeout(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithDeployment("deployment-pod-phase-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.WaitForPodPhase(resources.Deployments[0], corev1.PodFailed, 300*time.Millisecond)
+
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected a WaitTimeoutError, got %v", err)
+	}
+
+	if pods := timeoutErr.Resources[0].Pods; len(pods) != 1 || pods[0].Phase != corev1.PodRunning {
+		t.Errorf("Expected the diagnostics to contain the running pod, got %+v", pods)
+	}
+}
+
+func TestWaitForPodWithoutSelector(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace()).
+		WithConfigMap("config-map-pod-state-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.WaitForPodPhase(resources.ConfigMaps[0], corev1.PodRunning, time.Second)
+	if !errors.Is(err, ErrNoPodSelector) {
+		t.Errorf("Expected ErrNoPodSelector, got %v", err)
+	}
+}
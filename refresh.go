@@ -0,0 +1,162 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getFunc reads an object by name, like the Get methods of the clientset.
+type getFunc[T client.Object] func(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+
+// Refresh re-reads every tracked object from the cluster and writes its
+// current state, including UID, resourceVersion, defaults and status, into the
+// objects held by r. Pointers taken from the slices stay valid.
+func (r *Resources) Refresh() error {
+	ctx := *r.Ctx
+	clientSet := r.TestClients.ClientSet
+	namespace := r.namespace()
+
+	if err := refreshAll(ctx, r.ConfigMaps, clientSet.CoreV1().ConfigMaps(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.Secrets, clientSet.CoreV1().Secrets(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.Services, clientSet.CoreV1().Services(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.Deployments, clientSet.AppsV1().Deployments(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.StatefulSets, clientSet.AppsV1().StatefulSets(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.DaemonSets, clientSet.AppsV1().DaemonSets(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.Jobs, clientSet.BatchV1().Jobs(namespace).Get); err != nil {
+		return err
+	}
+
+	if err := refreshAll(ctx, r.CronJobs, clientSet.BatchV1().CronJobs(namespace).Get); err != nil {
+		return err
+	}
+
+	for _, tracked := range r.Objects {
+		if err := r.refreshObject(ctx, tracked.Object); err != nil {
+			return err
+		}
+	}
+
+	for _, obj := range r.untracked {
+		if err := r.refreshObject(ctx, obj); err != nil {
+			return err
+		}
+	}
+
+	for obj := range r.applied {
+		if err := r.syncApplied(obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func refreshAll[T client.Object](ctx context.Context, objects []T, get getFunc[T]) error {
+	for _, obj := range objects {
+		current, err := get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get %s %s: %w", objectKind(obj), obj.GetName(), err)
+		}
+
+		writeBack(obj, current)
+	}
+
+	return nil
+}
+
+// refreshObject re-reads obj through the controller-runtime client.
+func (r *Resources) refreshObject(ctx context.Context, obj client.Object) error {
+	current, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return nil
+	}
+
+	if err := r.TestClients.K8sClient.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+		return fmt.Errorf("failed to get %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	writeBack(obj, current)
+
+	return nil
+}
+
+// createCopy returns a copy of obj without the metadata the API server set when
+// obj was created before, so that it can be created again.
+func createCopy[T client.Object](obj T) T {
+	fresh, ok := obj.DeepCopyObject().(T)
+	if !ok {
+		return obj
+	}
+
+	fresh.SetResourceVersion("")
+	fresh.SetUID("")
+	fresh.SetGeneration(0)
+	fresh.SetCreationTimestamp(metav1.Time{})
+	fresh.SetManagedFields(nil)
+
+	return fresh
+}
+
+// writeBack copies the state returned by the API server into obj in place.
+// The apiVersion and kind of obj are kept if the server response, like those
+// of the typed clients, does not carry them.
+func writeBack(obj, server client.Object) {
+	if obj == server || reflect.TypeOf(obj) != reflect.TypeOf(server) {
+		return
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(server).Elem())
+
+	if obj.GetObjectKind().GroupVersionKind().Empty() {
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+}
+
+// writeBackUnstructured converts the server response of an apply into the Go
+// type of obj and writes it back into obj.
+func writeBackUnstructured(obj client.Object, server *unstructured.Unstructured) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		writeBack(u, server)
+
+		return nil
+	}
+
+	current, ok := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+	if !ok {
+		return nil
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(server.Object, current); err != nil {
+		return fmt.Errorf("failed to convert %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	writeBack(obj, current)
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateWritesBack(t *testing.T) {
+	resources := NewFake(t, context.Background(), WithTestNamespace()).
+		WithDeployment("deployment-refresh-1").
+		And().
+		WithJob("job-refresh-1").
+		And()
+
+	deployment := resources.Deployments[0]
+
+	_, err := resources.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deployment.UID == "" || deployment.CreationTimestamp.IsZero() {
+		t.Errorf("Expected the deployment to carry UID and creation timestamp, got %q and %v",
+			deployment.UID, deployment.CreationTimestamp)
+	}
+
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 1 {
+		t.Errorf("Expected the defaulted replicas to be written back, got %v", deployment.Spec.Replicas)
+	}
+
+	if deployment.Kind != "Deployment" {
+		t.Errorf("Expected the kind to be kept, got %q", deployment.Kind)
+	}
+
+	if resources.Jobs[0].Spec.Selector == nil {
+		t.Error("Expected the generated selector of the job to be written back")
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-creating objects that carry server state must work as well.
+	uid := deployment.UID
+
+	_, err = resources.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deployment.UID == uid {
+		t.Errorf("Expected a re-created deployment with a new UID, got %s again", uid)
+	}
+
+	if deployment.Status.AvailableReplicas != 0 {
+		t.Errorf("Expected the status of the re-created deployment to be reset, got %d available replicas",
+			deployment.Status.AvailableReplicas)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithDeployment("deployment-refresh-2").
+		WithConfigMap("config-map-refresh-2").
+		And().
+		WithJob("job-refresh-2").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resources.Deployments[0].Status.AvailableReplicas != 1 {
+		t.Errorf("Expected the refreshed deployment to have 1 available replica, got %d",
+			resources.Deployments[0].Status.AvailableReplicas)
+	}
+
+	if !isJobFinished(resources.Jobs[0]) {
+		t.Errorf("Expected the refreshed job to be finished, got %v", resources.Jobs[0].Status.Conditions)
+	}
+
+	configMap, err := resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Get(
+		context.Background(), "config-map-refresh-2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configMap.Data = map[string]string{"changed": "true"}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().ConfigMaps(resources.Namespace).Update(
+		context.Background(), configMap, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resources.ConfigMaps[0].Data["changed"] != "true" {
+		t.Errorf("Expected the refreshed configmap to have the changed data, got %v", resources.ConfigMaps[0].Data)
+	}
+
+	// Update must work with the refreshed resourceVersion.
+	resources.ConfigMaps[0].Data["changed"] = "again"
+
+	_, err = resources.Update(resources.ConfigMaps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,124 @@
+package k8stest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+const yamlDocumentSeparator = "---\n"
+
+// serverPopulatedFields are the metadata fields the API server sets on objects.
+var serverPopulatedFields = []string{"resourceVersion", "uid", "generation", "creationTimestamp", "managedFields"}
+
+// Render writes all tracked resources to w as a multi-document YAML manifest
+// that can be passed to kubectl apply. The documents are ordered like Create
+// creates them, i.e. dependencies such as ConfigMaps and Secrets come before
+// the workloads that mount them. Status and other server-populated fields are
+// left out.
+func (r *Resources) Render(w io.Writer) error {
+	for i, obj := range r.orderedObjects() {
+		document, err := renderObject(obj)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			if _, err := io.WriteString(w, yamlDocumentSeparator); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write(document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarshalYAML returns the manifest written by Render.
+func (r *Resources) MarshalYAML() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := r.Render(&buffer); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// orderedObjects returns all tracked resources in the order Create creates them.
+func (r *Resources) orderedObjects() []client.Object {
+	var objects []client.Object
+
+	objects = appendObjects(objects, r.ConfigMaps)
+	objects = appendObjects(objects, r.Secrets)
+
+	for _, tracked := range r.Objects {
+		objects = append(objects, tracked.Object)
+	}
+
+	objects = appendObjects(objects, r.Services)
+	objects = appendObjects(objects, r.Deployments)
+	objects = appendObjects(objects, r.StatefulSets)
+	objects = appendObjects(objects, r.DaemonSets)
+	objects = appendObjects(objects, r.Jobs)
+	objects = appendObjects(objects, r.CronJobs)
+
+	return objects
+}
+
+func appendObjects[T client.Object](objects []client.Object, typed []T) []client.Object {
+	for _, obj := range typed {
+		objects = append(objects, obj)
+	}
+
+	return objects
+}
+
+// renderObject marshals obj to YAML with apiVersion and kind set and without
+// status and server-populated metadata.
+func renderObject(obj client.Object) ([]byte, error) {
+	rendered, err := toUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(rendered.Object)
+}
+
+// toUnstructured converts obj to its unstructured form with apiVersion and kind
+// set and without status and server-populated metadata, so that objects read
+// back from the cluster render and apply like freshly built ones.
+func toUnstructured(obj client.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %w", objectKind(obj), obj.GetName(), err)
+	}
+
+	rendered := &unstructured.Unstructured{Object: content}
+
+	if rendered.GetKind() == "" {
+		gvk, err := apiutil.GVKForObject(obj, SetupScheme())
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine kind of %s: %w", obj.GetName(), err)
+		}
+
+		rendered.SetGroupVersionKind(gvk)
+	}
+
+	unstructured.RemoveNestedField(rendered.Object, "status")
+
+	for _, field := range serverPopulatedFields {
+		unstructured.RemoveNestedField(rendered.Object, "metadata", field)
+	}
+
+	return rendered, nil
+}
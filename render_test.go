@@ -0,0 +1,67 @@
+package k8stest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRender(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients()).
+		WithDeployment("deployment-render-1").
+		WithConfigMap("config-map-render-1").
+		WithSecret("secret-render-1").
+		And().
+		WithObject(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "service-account-render-1"}})
+
+	manifest, err := resources.MarshalYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := strings.Split(string(manifest), yamlDocumentSeparator)
+	expected := [][]string{
+		{"apiVersion: v1\n", "kind: ConfigMap\n"},
+		{"apiVersion: v1\n", "kind: Secret\n"},
+		{"apiVersion: v1\n", "kind: ServiceAccount\n"},
+		{"apiVersion: apps/v1\n", "kind: Deployment\n"},
+	}
+
+	if len(documents) != len(expected) {
+		t.Fatalf("Expected %d documents, got %d:\n%s", len(expected), len(documents), manifest)
+	}
+
+	for i, lines := range expected {
+		for _, line := range lines {
+			if !strings.Contains(documents[i], line) {
+				t.Errorf("Expected document %d to contain %q, got:\n%s", i, line, documents[i])
+			}
+		}
+	}
+
+	if strings.Contains(string(manifest), "status:") || strings.Contains(string(manifest), "creationTimestamp") {
+		t.Errorf("Expected no server-populated fields, got:\n%s", manifest)
+	}
+
+	for _, mount := range []string{"mountPath: /etc/config", "mountPath: /etc/secret"} {
+		if !strings.Contains(documents[3], mount) {
+			t.Errorf("Expected the deployment to contain %q, got:\n%s", mount, documents[3])
+		}
+	}
+
+	loaded, err := NewWithClients(t, context.Background(), NewFakeTestClients()).FromYAML(bytes.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Deployments) != 1 || len(loaded.ConfigMaps) != 1 || len(loaded.Secrets) != 1 ||
+		len(loaded.Objects) != 1 {
+		t.Errorf("Expected the rendered manifest to load again, got %d deployments, %d configmaps, "+
+			"%d secrets and %d objects", len(loaded.Deployments), len(loaded.ConfigMaps), len(loaded.Secrets),
+			len(loaded.Objects))
+	}
+}
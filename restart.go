@@ -0,0 +1,255 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	// ErrUnexpectedRestart is returned by ExpectNoRestart when pods of the
+	// workload were replaced or restarted.
+	ErrUnexpectedRestart = errors.New("pods restarted unexpectedly")
+	// ErrNoPodsToRestart is returned by ExpectRestart when the workload has no
+	// pods, so no restart could be observed.
+	ErrNoPodsToRestart = errors.New("workload has no pods to restart")
+)
+
+// RestartReport describes how the pods of a workload changed compared to the
+// snapshot taken before a mutation. Replaced lists the old pods that were
+// deleted or are terminating, Restarted the old pods with a container that
+// restarted in place. The template hashes are the pod-template-hash, or for
+// StatefulSets and DaemonSets the controller-revision-hash, labels of the pods
+// that are not terminating.
+type RestartReport struct {
+	Replaced           []string
+	Restarted          []string
+	TemplateHashBefore string
+	TemplateHashAfter  string
+}
+
+// Changed reports whether pods were replaced or restarted, or the template
+// hash changed.
+func (r *RestartReport) Changed() bool {
+	return len(r.Replaced) > 0 || len(r.Restarted) > 0 || r.TemplateHashBefore != r.TemplateHashAfter
+}
+
+func (r *RestartReport) String() string {
+	return fmt.Sprintf("replaced pods [%s], restarted pods [%s], template hash %q -> %q",
+		strings.Join(r.Replaced, ", "), strings.Join(r.Restarted, ", "), r.TemplateHashBefore,
+		r.TemplateHashAfter)
+}
+
+// podSnapshot is the state of a pod of a workload before a mutation.
+type podSnapshot struct {
+	name     string
+	restarts int32
+}
+
+// restartSnapshot is the state of the pods of a workload before a mutation.
+type restartSnapshot struct {
+	pods         map[types.UID]podSnapshot
+	ready        int
+	templateHash string
+}
+
+// ExpectRestart takes a snapshot of the pods of workload, which is a
+// Deployment, StatefulSet or DaemonSet, calls mutate, e.g. to change a
+// ConfigMap the workload consumes, and waits until every pod of the snapshot
+// was replaced or restarted and at least as many pods are ready as before.
+// The report lists the pods that were replaced, also when the wait failed.
+// ErrNoPodsToRestart is returned without calling mutate when the workload has
+// no pods.
+func (r *Resources) ExpectRestart(workload client.Object, mutate func(),
+	timeout ...time.Duration,
+) (*RestartReport, error) {
+	applicableTimeout := r.Timeout
+
+	if len(timeout) > 0 {
+		applicableTimeout = timeout[0]
+	}
+
+	snapshot, err := r.snapshotPods(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshot.pods) == 0 {
+		return nil, fmt.Errorf("failed to expect the restart of %s %s: %w", workloadKind(workload),
+			workload.GetName(), ErrNoPodsToRestart)
+	}
+
+	mutate()
+
+	report := &RestartReport{TemplateHashBefore: snapshot.templateHash, TemplateHashAfter: snapshot.templateHash}
+
+	target, source, err := r.podsWaitTarget(workload, func(pods []corev1.Pod) (bool, error) {
+		report = snapshot.compare(pods)
+
+		return len(report.Replaced)+len(report.Restarted) == len(snapshot.pods) &&
+			countReadyPods(pods) >= snapshot.ready, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.waitFor(applicableTimeout, []waitTarget{target}, []waitSource{source})
+	if err != nil {
+		return report, fmt.Errorf("failed to wait for the restart of %s %s (%s): %w", workloadKind(workload),
+			workload.GetName(), report, err)
+	}
+
+	return report, nil
+}
+
+// ExpectNoRestart takes a snapshot of the pods of workload, calls mutate and
+// watches the pods for the whole timeout. It returns ErrUnexpectedRestart as
+// soon as a pod of the snapshot is replaced or restarted or the template hash
+// changes, e.g. because the mutation triggered a rollout.
+func (r *Resources) ExpectNoRestart(workload client.Object, mutate func(),
+	timeout ...time.Duration,
+) (*RestartReport, error) {
+	applicableTimeout := r.Timeout
+
+	if len(timeout) > 0 {
+		applicableTimeout = timeout[0]
+	}
+
+	snapshot, err := r.snapshotPods(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	mutate()
+
+	report := &RestartReport{TemplateHashBefore: snapshot.templateHash, TemplateHashAfter: snapshot.templateHash}
+
+	target, source, err := r.podsWaitTarget(workload, func(pods []corev1.Pod) (bool, error) {
+		report = snapshot.compare(pods)
+		if report.Changed() {
+			return false, fmt.Errorf("%s: %w", report, ErrUnexpectedRestart)
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The target never becomes ready, so the wait only ends with a restart or
+	// when the timeout expires.
+	_, err = r.watchTargets(applicableTimeout, []waitTarget{target}, []waitSource{source})
+	if errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrUnexpectedRestart) {
+		return report, nil
+	}
+
+	return report, err
+}
+
+// snapshotPods records the pods of workload and their restart counts.
+func (r *Resources) snapshotPods(workload client.Object) (*restartSnapshot, error) {
+	pods, err := r.Pods(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &restartSnapshot{
+		pods:         map[types.UID]podSnapshot{},
+		ready:        countReadyPods(pods),
+		templateHash: templateHashOf(pods),
+	}
+
+	for i := range pods {
+		if pods[i].DeletionTimestamp == nil {
+			snapshot.pods[pods[i].UID] = podSnapshot{name: pods[i].Name, restarts: restartCountOf(&pods[i])}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// compare returns how pods differ from the snapshot.
+func (s *restartSnapshot) compare(pods []corev1.Pod) *RestartReport {
+	report := &RestartReport{TemplateHashBefore: s.templateHash, TemplateHashAfter: templateHashOf(pods)}
+
+	current := map[types.UID]*corev1.Pod{}
+
+	for i := range pods {
+		current[pods[i].UID] = &pods[i]
+	}
+
+	for uid, old := range s.pods {
+		pod, ok := current[uid]
+
+		switch {
+		case !ok || pod.DeletionTimestamp != nil:
+			report.Replaced = append(report.Replaced, old.name)
+		case restartCountOf(pod) > old.restarts:
+			report.Restarted = append(report.Restarted, old.name)
+		}
+	}
+
+	sort.Strings(report.Replaced)
+	sort.Strings(report.Restarted)
+
+	return report
+}
+
+// countReadyPods returns the number of pods that are ready and not terminating.
+func countReadyPods(pods []corev1.Pod) int {
+	ready := 0
+
+	for i := range pods {
+		if pods[i].DeletionTimestamp == nil && isPodReady(&pods[i]) {
+			ready++
+		}
+	}
+
+	return ready
+}
+
+// restartCountOf returns the sum of the restart counts of the containers of pod.
+func restartCountOf(pod *corev1.Pod) int32 {
+	var restarts int32
+
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+
+	return restarts
+}
+
+// templateHashOf returns the distinct template hashes of the pods that are not
+// terminating, sorted and separated by commas.
+func templateHashOf(pods []corev1.Pod) string {
+	seen := map[string]bool{}
+
+	var hashes []string
+
+	for i := range pods {
+		if pods[i].DeletionTimestamp != nil {
+			continue
+		}
+
+		hash, ok := pods[i].Labels[podTemplateHashLabel]
+		if !ok {
+			hash, ok = pods[i].Labels[controllerRevisionHashLabel]
+		}
+
+		if ok && !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+
+	sort.Strings(hashes)
+
+	return strings.Join(hashes, ",")
+}
@@ -0,0 +1,153 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestExpectRestart(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithRolloutWait()).
+		WithDeployment("deployment-restart-1").
+		WithConfigMap("config-map-restart-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.Pods(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the ConfigMap and roll the deployment like a config reloader does.
+	report, err := resources.ExpectRestart(resources.Deployments[0], func() {
+		_, err := resources.PatchConfigMapData("config-map-restart-1", map[string]string{"key": "changed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = resources.MutateDeployment("deployment-restart-1", func(deployment *appsv1.Deployment) {
+			deployment.Spec.Template.Annotations = map[string]string{"checksum/config": "changed"}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Replaced) != 1 || report.Replaced[0] != pods[0].Name {
+		t.Errorf("Expected pod %s to be replaced, got %s", pods[0].Name, report)
+	}
+
+	if report.TemplateHashBefore == report.TemplateHashAfter {
+		t.Errorf("Expected the template hash to change, got %s", report)
+	}
+}
+
+func TestExpectNoRestart(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithRolloutWait()).
+		WithDeployment("deployment-restart-2").
+		WithConfigMap("config-map-restart-2").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := resources.ExpectNoRestart(resources.Deployments[0], func() {
+		_, err := resources.PatchConfigMapData("config-map-restart-2", map[string]string{"key": "changed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Changed() {
+		t.Errorf("Expected no pod to change, got %s", report)
+	}
+
+	report, err = resources.ExpectNoRestart(resources.Deployments[0], func() {
+		_, err := resources.PatchDeploymentImage("deployment-restart-2", "noop-container", "busybox:stable")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}, 2*time.Second)
+	if !errors.Is(err, ErrUnexpectedRestart) {
+		t.Fatalf("Expected ErrUnexpectedRestart after changing the image, got %v", err)
+	}
+
+	if !report.Changed() {
+		t.Errorf("Expected the report to show the rollout, got %s", report)
+	}
+}
+
+func TestExpectRestartInPlace(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithSimulator(WithImageFailure("busybox:latest", ReasonCrashLoopBackOff))).
+		WithStatefulSet("statefulset-restart-3").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := resources.WaitForCrashLoop(resources.StatefulSets[0], 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The container keeps crashing, so its restart count increases without the
+	// pod being replaced.
+	report, err := resources.ExpectRestart(resources.StatefulSets[0], func() {}, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Restarted) != 1 || report.Restarted[0] != pod.Name {
+		t.Errorf("Expected pod %s to be restarted, got %s", pod.Name, report)
+	}
+
+	if len(report.Replaced) != 0 {
+		t.Errorf("Expected no pod to be replaced, got %s", report)
+	}
+}
+
+func TestExpectRestartWithoutPods(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace()).
+		WithDeployment("deployment-restart-4").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutated := false
+
+	report, err := resources.ExpectRestart(resources.Deployments[0], func() { mutated = true }, 300*time.Millisecond)
+	if !errors.Is(err, ErrNoPodsToRestart) {
+		t.Fatalf("Expected ErrNoPodsToRestart, got %v (%s)", err, report)
+	}
+
+	if mutated {
+		t.Error("Expected mutate not to be called without pods")
+	}
+}
@@ -0,0 +1,81 @@
+package k8stest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// progressDeadlineExceededReason is the reason of the Progressing condition
+// of a Deployment whose rollout is stuck.
+const progressDeadlineExceededReason = "ProgressDeadlineExceeded"
+
+var (
+	// ErrProgressDeadlineExceeded is returned when waiting for the rollout of a
+	// Deployment that exceeded its progress deadline.
+	ErrProgressDeadlineExceeded = errors.New("deployment exceeded its progress deadline")
+	// ErrRolloutStatusUnavailable is returned when waiting for the rollout of a
+	// StatefulSet that does not use the RollingUpdate strategy.
+	ErrRolloutStatusUnavailable = errors.New("rollout status is only available for the RollingUpdate strategy")
+)
+
+// WithRolloutWait returns an Option that makes Wait wait for the rollouts of
+// Deployments and StatefulSets to complete, like kubectl rollout status does,
+// instead of only comparing the ready replicas. Use it to wait for the changes
+// of an Update or Patch to be rolled out.
+func WithRolloutWait() Option {
+	return func(_ *testing.T, r *Resources) {
+		r.WaitForRollout = true
+	}
+}
+
+// isDeploymentRolledOut reports whether the controller observed the latest
+// spec of deployment, all replicas were updated and are available, and no
+// replicas of old ReplicaSets are left.
+func isDeploymentRolledOut(deployment *appsv1.Deployment) (bool, error) {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, nil
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == progressDeadlineExceededReason {
+			return false, fmt.Errorf("%w: %s", ErrProgressDeadlineExceeded, condition.Message)
+		}
+	}
+
+	replicas := replicasOf(deployment.Spec.Replicas)
+	status := deployment.Status
+
+	return status.UpdatedReplicas >= replicas &&
+		status.Replicas <= status.UpdatedReplicas &&
+		status.AvailableReplicas >= status.UpdatedReplicas, nil
+}
+
+// isStatefulSetRolledOut reports whether the controller observed the latest
+// spec of statefulSet, all replicas are ready and, unless a partition is set,
+// all pods run the update revision.
+func isStatefulSetRolledOut(statefulSet *appsv1.StatefulSet) (bool, error) {
+	strategy := statefulSet.Spec.UpdateStrategy
+	if strategy.Type != "" && strategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return false, fmt.Errorf("%w, statefulset %s uses %s", ErrRolloutStatusUnavailable, statefulSet.Name, strategy.Type)
+	}
+
+	status := statefulSet.Status
+	if status.ObservedGeneration == 0 || statefulSet.Generation > status.ObservedGeneration {
+		return false, nil
+	}
+
+	replicas := replicasOf(statefulSet.Spec.Replicas)
+	if status.ReadyReplicas < replicas {
+		return false, nil
+	}
+
+	if strategy.RollingUpdate != nil && strategy.RollingUpdate.Partition != nil &&
+		*strategy.RollingUpdate.Partition > 0 {
+		return status.UpdatedReplicas >= replicas-*strategy.RollingUpdate.Partition, nil
+	}
+
+	return status.UpdateRevision == status.CurrentRevision, nil
+}
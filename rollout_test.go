@@ -0,0 +1,183 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeploymentRolloutWait(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithRolloutWait()).
+		WithDeployment("deployment-rollout-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.PatchDeploymentImage("deployment-rollout-1", "noop-container", "busybox:stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "app=deployment-rollout-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp == nil && pod.Spec.Containers[0].Image != "busybox:stable" {
+			t.Errorf("Expected pod %s to run the new image, got %s", pod.Name, pod.Spec.Containers[0].Image)
+		}
+	}
+}
+
+func TestStatefulSetRolloutWait(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace(), WithRolloutWait()).
+		WithStatefulSet("statefulset-rollout-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statefulSet, err := resources.MutateStatefulSet("statefulset-rollout-1", func(statefulSet *appsv1.StatefulSet) {
+		statefulSet.Spec.Replicas = int32Ptr(2)
+		statefulSet.Spec.Template.Spec.Containers[0].Image = "busybox:stable"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(3 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statefulSet.Status.UpdatedReplicas != 2 || statefulSet.Status.CurrentRevision != statefulSet.Status.UpdateRevision {
+		t.Errorf("Expected both replicas to run the update revision, got %+v", statefulSet.Status)
+	}
+}
+
+func TestIsDeploymentRolledOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   appsv1.DeploymentStatus
+		expected bool
+		err      error
+	}{
+		{
+			name:   "spec not observed",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 2, Replicas: 2, AvailableReplicas: 2},
+		},
+		{
+			name:   "replicas not updated",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 1, Replicas: 2, AvailableReplicas: 2},
+		},
+		{
+			name:   "old replicas left",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 2, Replicas: 3, AvailableReplicas: 3},
+		},
+		{
+			name:   "updated replicas not available",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 2, Replicas: 2, AvailableReplicas: 1},
+		},
+		{
+			name: "progress deadline exceeded",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{{
+				Type:   appsv1.DeploymentProgressing,
+				Reason: progressDeadlineExceededReason,
+			}}},
+			err: ErrProgressDeadlineExceeded,
+		},
+		{
+			name:     "rolled out",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, UpdatedReplicas: 2, Replicas: 2, AvailableReplicas: 2},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+				Status:     test.status,
+			}
+
+			rolledOut, err := isDeploymentRolledOut(deployment)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Expected error %v, got %v", test.err, err)
+			}
+
+			if rolledOut != test.expected {
+				t.Errorf("Expected rolled out to be %t, got %t", test.expected, rolledOut)
+			}
+		})
+	}
+}
+
+func TestIsStatefulSetRolledOut(t *testing.T) {
+	onDelete := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType},
+		},
+	}
+
+	if _, err := isStatefulSetRolledOut(onDelete); !errors.Is(err, ErrRolloutStatusUnavailable) {
+		t.Errorf("Expected ErrRolloutStatusUnavailable for OnDelete, got %v", err)
+	}
+
+	partitioned := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: int32Ptr(3),
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: int32Ptr(2)},
+			},
+		},
+		Status: appsv1.StatefulSetStatus{
+			ObservedGeneration: 1,
+			ReadyReplicas:      3,
+			UpdatedReplicas:    1,
+			CurrentRevision:    "old",
+			UpdateRevision:     "new",
+		},
+	}
+
+	if rolledOut, err := isStatefulSetRolledOut(partitioned); err != nil || !rolledOut {
+		t.Errorf("Expected the partitioned rollout to be complete, got %t and %v", rolledOut, err)
+	}
+
+	partitioned.Spec.UpdateStrategy.RollingUpdate = nil
+
+	if rolledOut, err := isStatefulSetRolledOut(partitioned); err != nil || rolledOut {
+		t.Errorf("Expected the rollout to wait for the update revision, got %t and %v", rolledOut, err)
+	}
+}
@@ -0,0 +1,169 @@
+package k8stest
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultServicePort is exposed by Services whose selected pods declare no
+// container ports, because a ClusterIP or NodePort Service needs at least one.
+const defaultServicePort = 80
+
+// WithService adds a ClusterIP Service selecting the pods labeled app=name.
+// Its ports are derived from the container ports of the tracked Deployments,
+// StatefulSets and DaemonSets whose pods it selects, so it is best added after the workload.
+func (r *Resources) WithService(name string) *Resources {
+	return r.withService(name, corev1.ServiceTypeClusterIP, "", map[string]string{"app": name})
+}
+
+// WithNodePortService works like WithService but creates a NodePort Service.
+// The node ports are assigned by the API server.
+func (r *Resources) WithNodePortService(name string) *Resources {
+	return r.withService(name, corev1.ServiceTypeNodePort, "", map[string]string{"app": name})
+}
+
+// WithHeadlessService works like WithService but creates a headless Service,
+// i.e. one without a cluster IP.
+func (r *Resources) WithHeadlessService(name string) *Resources {
+	return r.withService(name, corev1.ServiceTypeClusterIP, corev1.ClusterIPNone, map[string]string{"app": name})
+}
+
+// WithoutHeadlessService removes the governing headless Service that
+// WithStatefulSet added for the StatefulSet, e.g. because the test creates it
+// itself.
+func (s *StatefulSet) WithoutHeadlessService() *StatefulSet {
+	statefulSet := s.StatefulSets[len(s.StatefulSets)-1]
+
+	s.Services = slices.DeleteFunc(slices.Clone(s.Services), func(service *corev1.Service) bool {
+		return service.Name == statefulSet.Spec.ServiceName
+	})
+
+	return s
+}
+
+func (r *Resources) withService(name string, serviceType corev1.ServiceType, clusterIP string,
+	selector map[string]string,
+) *Resources {
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace(),
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:      serviceType,
+			ClusterIP: clusterIP,
+			Selector:  selector,
+			Ports:     r.servicePorts(selector, clusterIP == corev1.ClusterIPNone),
+		},
+	}
+
+	r.Services = append(r.Services, service)
+	r.ApplyOptions(service)
+
+	return r
+}
+
+// servicePorts returns one ServicePort per distinct container port of the pods
+// that the given selector matches. Headless Services may go without ports, all
+// other Services fall back to defaultServicePort.
+func (r *Resources) servicePorts(selector map[string]string, headless bool) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+
+	seen := map[string]bool{}
+
+	for _, template := range r.podTemplates() {
+		if !labels.SelectorFromSet(selector).Matches(labels.Set(template.Labels)) {
+			continue
+		}
+
+		for _, container := range template.Spec.Containers {
+			for _, containerPort := range container.Ports {
+				key := string(containerPort.Protocol) + "/" + strconv.Itoa(int(containerPort.ContainerPort))
+				if seen[key] {
+					continue
+				}
+
+				seen[key] = true
+				ports = append(ports, corev1.ServicePort{
+					Name:       servicePortName(containerPort, len(ports)),
+					Protocol:   containerPort.Protocol,
+					Port:       containerPort.ContainerPort,
+					TargetPort: intstr.FromInt32(containerPort.ContainerPort),
+				})
+			}
+		}
+	}
+
+	if len(ports) == 0 && !headless {
+		ports = append(ports, corev1.ServicePort{
+			Name:       "port-0",
+			Port:       defaultServicePort,
+			TargetPort: intstr.FromInt32(defaultServicePort),
+		})
+	}
+
+	return ports
+}
+
+// withGoverningService adds the headless Service that gives the pods of
+// statefulSet their stable network identity, unless it is already tracked.
+func (r *Resources) withGoverningService(statefulSet *appsv1.StatefulSet) {
+	name := statefulSet.Spec.ServiceName
+	if name == "" || containsNamed(r.Services, name) {
+		return
+	}
+
+	r.withService(name, corev1.ServiceTypeClusterIP, corev1.ClusterIPNone,
+		maps.Clone(statefulSet.Spec.Template.Labels))
+}
+
+// podTemplates returns the pod templates of all tracked workloads.
+func (r *Resources) podTemplates() []*corev1.PodTemplateSpec {
+	templates := make([]*corev1.PodTemplateSpec, 0, len(r.Deployments)+len(r.StatefulSets)+len(r.DaemonSets))
+
+	for _, deployment := range r.Deployments {
+		templates = append(templates, &deployment.Spec.Template)
+	}
+
+	for _, statefulSet := range r.StatefulSets {
+		templates = append(templates, &statefulSet.Spec.Template)
+	}
+
+	for _, daemonSet := range r.DaemonSets {
+		templates = append(templates, &daemonSet.Spec.Template)
+	}
+
+	return templates
+}
+
+func servicePortName(containerPort corev1.ContainerPort, index int) string {
+	if containerPort.Name != "" {
+		return containerPort.Name
+	}
+
+	return "port-" + strconv.Itoa(index)
+}
+
+// isServiceReady reports whether the API server has set up the Service, i.e.
+// assigned a cluster IP unless the Service is headless.
+func isServiceReady(service *corev1.Service) bool {
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return true
+	}
+
+	return service.Spec.ClusterIP != ""
+}
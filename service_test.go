@@ -0,0 +1,110 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ContainerPortOption returns a ResourceOption that exposes the given port on
+// the first container of Deployments and StatefulSets.
+func ContainerPortOption(name string, port int32) ResourceOption {
+	return func(obj runtime.Object) {
+		var podSpec *corev1.PodSpec
+
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			podSpec = &o.Spec.Template.Spec
+		case *appsv1.StatefulSet:
+			podSpec = &o.Spec.Template.Spec
+		default:
+			return
+		}
+
+		podSpec.Containers[0].Ports = append(podSpec.Containers[0].Ports, corev1.ContainerPort{
+			Name:          name,
+			ContainerPort: port,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+}
+
+func TestServicePorts(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients()).
+		WithResourceOption(ContainerPortOption("http", 8080)).
+		WithDeployment("deployment-service-1").
+		And().
+		WithService("deployment-service-1").
+		WithNodePortService("no-workload")
+
+	service := resources.Services[0]
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Name != "http" || service.Spec.Ports[0].Port != 8080 {
+		t.Errorf("Expected port http/8080 derived from the deployment, got %v", service.Spec.Ports)
+	}
+
+	nodePortService := resources.Services[1]
+	if nodePortService.Spec.Type != corev1.ServiceTypeNodePort {
+		t.Errorf("Expected a NodePort service, got %s", nodePortService.Spec.Type)
+	}
+
+	if len(nodePortService.Spec.Ports) != 1 || nodePortService.Spec.Ports[0].Port != defaultServicePort {
+		t.Errorf("Expected the default port %d, got %v", defaultServicePort, nodePortService.Spec.Ports)
+	}
+}
+
+func TestStatefulSetHeadlessService(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithStatefulSet("statefulset-service-1").
+		And().
+		WithService("statefulset-service-1-public").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := resources.TestClients.ClientSet.CoreV1().Services(resources.Namespace).Get(
+		context.Background(), "statefulset-service-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected governing service to exist: %v", err)
+	}
+
+	if service.Spec.ClusterIP != corev1.ClusterIPNone {
+		t.Errorf("Expected governing service to be headless, got cluster IP %q", service.Spec.ClusterIP)
+	}
+
+	if service.Spec.Selector["app"] != "statefulset-service-1" {
+		t.Errorf("Expected governing service to select the statefulset pods, got %v", service.Spec.Selector)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.TestClients.ClientSet.CoreV1().Services(resources.Namespace).Get(
+		context.Background(), "statefulset-service-1", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected governing service to be deleted, got %v", err)
+	}
+}
+
+func TestStatefulSetWithoutHeadlessService(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients()).
+		WithStatefulSet("statefulset-service-2").
+		WithoutHeadlessService()
+
+	if len(resources.Services) != 0 {
+		t.Errorf("Expected no services, got %d", len(resources.Services))
+	}
+}
@@ -0,0 +1,1302 @@
+package k8stest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	simulatorResyncPeriod = 10 * time.Millisecond
+
+	podTemplateHashLabel        = "pod-template-hash"
+	controllerRevisionHashLabel = "controller-revision-hash"
+	statefulSetPodNameLabel     = "statefulset.kubernetes.io/pod-name"
+
+	// ReasonCrashLoopBackOff can be passed to WithImageFailure to let the
+	// containers of an image crash and restart repeatedly.
+	ReasonCrashLoopBackOff = "CrashLoopBackOff"
+	// ReasonError can be passed to WithImageFailure to let the containers of
+	// an image exit with code 1. Pods of Jobs fail, all other pods end up in
+	// ReasonCrashLoopBackOff.
+	ReasonError = "Error"
+
+	defaultBackoffLimit = 6
+	defaultNodes        = 1
+	// defaultTerminationGracePeriod is the termination grace period of pods
+	// that do not set one.
+	defaultTerminationGracePeriod int64 = 30
+	// exitCodeSIGKILL is the exit code of a container killed with SIGKILL.
+	exitCodeSIGKILL int32 = 128 + 9
+)
+
+// SimulatorOption configures a Simulator.
+type SimulatorOption func(s *Simulator)
+
+// Simulator emulates the Deployment, ReplicaSet, StatefulSet, DaemonSet and Job
+// controllers, the garbage collector and the kubelet for clusters that run none of them,
+// such as the fake clientset created by NewFakeTestClients. It watches the
+// workloads, creates ReplicaSets and pods for them and advances their status,
+// so that Wait and DeleteAndWait behave as they would against a real cluster.
+type Simulator struct {
+	clientSet        kubernetes.Interface
+	podReadyDelay    time.Duration
+	podDeletionDelay time.Duration
+	nodes            int32
+
+	mu                  sync.Mutex
+	imageFailures       map[string]string
+	terminationMessages map[string]string
+	sigtermIgnored      map[string]bool
+	firstSeen           map[string]time.Time
+	deleting            map[string]time.Time
+	trigger             chan struct{}
+}
+
+// ownerKey identifies a controller referenced by an owner reference.
+type ownerKey struct {
+	kind      string
+	namespace string
+	name      string
+	uid       types.UID
+}
+
+type statusPatchFunc[T any] func(ctx context.Context, name string, pt types.PatchType, data []byte,
+	opts metav1.PatchOptions, subresources ...string) (T, error)
+
+// WithPodReadyDelay returns a SimulatorOption that sets the time between the
+// creation of a pod and its containers becoming ready. The default is zero.
+func WithPodReadyDelay(delay time.Duration) SimulatorOption {
+	return func(s *Simulator) {
+		s.podReadyDelay = delay
+	}
+}
+
+// WithPodDeletionDelay returns a SimulatorOption that sets how long pods stay
+// terminating after the simulator decided to delete them. The default is zero.
+func WithPodDeletionDelay(delay time.Duration) SimulatorOption {
+	return func(s *Simulator) {
+		s.podDeletionDelay = delay
+	}
+}
+
+// WithNodes returns a SimulatorOption that sets the number of nodes
+// DaemonSets run a pod on. The default is one.
+func WithNodes(count int32) SimulatorOption {
+	return func(s *Simulator) {
+		s.nodes = count
+	}
+}
+
+// WithImageFailure returns a SimulatorOption that makes every container using
+// image fail with the given waiting reason, e.g. "ErrImagePull" or
+// ReasonCrashLoopBackOff.
+func WithImageFailure(image, reason string) SimulatorOption {
+	return func(s *Simulator) {
+		s.imageFailures[image] = reason
+	}
+}
+
+// WithTerminationMessage returns a SimulatorOption that makes every container
+// using image report message as its termination message when its pod is
+// deleted, like the containers of this package do by writing to their
+// termination log on SIGTERM.
+func WithTerminationMessage(image, message string) SimulatorOption {
+	return func(s *Simulator) {
+		s.terminationMessages[image] = message
+	}
+}
+
+// WithSIGTERMIgnored returns a SimulatorOption that makes every container using
+// image ignore SIGTERM, so that it is killed with SIGKILL once the termination
+// grace period of its pod is over. By default, containers exit with code 0
+// right away when their pod is deleted.
+func WithSIGTERMIgnored(image string) SimulatorOption {
+	return func(s *Simulator) {
+		s.sigtermIgnored[image] = true
+	}
+}
+
+// WithSimulator returns an Option that starts a Simulator on the clientset of
+// the Resources object and stops it through t.Cleanup. It is meant for fake
+// clients or API servers without controllers, e.g. envtest.
+func WithSimulator(opts ...SimulatorOption) Option {
+	return func(t *testing.T, r *Resources) {
+		t.Helper()
+
+		simulator := NewSimulator(r.TestClients.ClientSet, opts...)
+		ctx, cancel := context.WithCancel(context.WithoutCancel(*r.Ctx))
+
+		if err := simulator.Start(ctx); err != nil {
+			cancel()
+			t.Fatalf("Failed to start simulator: %v", err)
+		}
+
+		r.Simulator = simulator
+		t.Cleanup(cancel)
+	}
+}
+
+// NewSimulator creates a Simulator for the given clientset. Call Start to run it.
+func NewSimulator(clientSet kubernetes.Interface, opts ...SimulatorOption) *Simulator {
+	s := &Simulator{
+		clientSet:           clientSet,
+		imageFailures:       map[string]string{},
+		terminationMessages: map[string]string{},
+		sigtermIgnored:      map[string]bool{},
+		firstSeen:           map[string]time.Time{},
+		deleting:            map[string]time.Time{},
+		nodes:               defaultNodes,
+		trigger:             make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// FailImage makes containers using image fail with the given waiting reason from
+// now on. An empty reason removes the failure again.
+func (s *Simulator) FailImage(image, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reason == "" {
+		delete(s.imageFailures, image)
+
+		return
+	}
+
+	s.imageFailures[image] = reason
+}
+
+// Start watches Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs and
+// pods in all namespaces and reconciles them in the background until ctx is done.
+func (s *Simulator) Start(ctx context.Context) error {
+	apps := s.clientSet.AppsV1()
+	core := s.clientSet.CoreV1()
+
+	watchFuncs := []func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error){
+		apps.Deployments(metav1.NamespaceAll).Watch,
+		apps.StatefulSets(metav1.NamespaceAll).Watch,
+		apps.DaemonSets(metav1.NamespaceAll).Watch,
+		apps.ReplicaSets(metav1.NamespaceAll).Watch,
+		s.clientSet.BatchV1().Jobs(metav1.NamespaceAll).Watch,
+		core.Pods(metav1.NamespaceAll).Watch,
+	}
+
+	watches := make([]watch.Interface, 0, len(watchFuncs))
+
+	for _, watchFunc := range watchFuncs {
+		w, err := watchFunc(ctx, metav1.ListOptions{})
+		if err != nil {
+			stopWatches(watches)
+
+			return fmt.Errorf("failed to start simulator watch: %w", err)
+		}
+
+		watches = append(watches, w)
+		go s.forward(w)
+	}
+
+	go s.run(ctx, watches)
+
+	return nil
+}
+
+func stopWatches(watches []watch.Interface) {
+	for _, w := range watches {
+		w.Stop()
+	}
+}
+
+// forward turns watch events into reconcile triggers. It keeps draining the
+// watch so that the fake tracker never blocks on a full channel.
+func (s *Simulator) forward(w watch.Interface) {
+	for range w.ResultChan() {
+		select {
+		case s.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Simulator) run(ctx context.Context, watches []watch.Interface) {
+	defer stopWatches(watches)
+
+	ticker := time.NewTicker(simulatorResyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.trigger:
+		case <-ticker.C:
+		}
+
+		// Errors such as conflicts or objects deleted in the meantime are
+		// expected; the next resync simply tries again.
+		_ = s.reconcile(ctx)
+	}
+}
+
+func (s *Simulator) reconcile(ctx context.Context) error {
+	apps := s.clientSet.AppsV1()
+
+	deployments, err := apps.Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	statefulSets, err := apps.StatefulSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	daemonSets, err := apps.DaemonSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	replicaSets, err := apps.ReplicaSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	jobs, err := s.clientSet.BatchV1().Jobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	cronJobs, err := s.clientSet.BatchV1().CronJobs(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	pods, err := s.clientSet.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	owners := map[ownerKey]bool{}
+	for i := range deployments.Items {
+		owners[ownerKeyOf("Deployment", &deployments.Items[i])] = true
+	}
+
+	for i := range statefulSets.Items {
+		owners[ownerKeyOf("StatefulSet", &statefulSets.Items[i])] = true
+	}
+
+	for i := range daemonSets.Items {
+		owners[ownerKeyOf("DaemonSet", &daemonSets.Items[i])] = true
+	}
+
+	for i := range replicaSets.Items {
+		owners[ownerKeyOf("ReplicaSet", &replicaSets.Items[i])] = true
+	}
+
+	for i := range jobs.Items {
+		owners[ownerKeyOf("Job", &jobs.Items[i])] = true
+	}
+
+	for i := range cronJobs.Items {
+		owners[ownerKeyOf("CronJob", &cronJobs.Items[i])] = true
+	}
+
+	var errs []error
+
+	for i := range deployments.Items {
+		errs = append(errs, s.reconcileDeployment(ctx, &deployments.Items[i], replicaSets.Items, pods.Items))
+	}
+
+	for i := range statefulSets.Items {
+		errs = append(errs, s.reconcileStatefulSet(ctx, &statefulSets.Items[i], pods.Items))
+	}
+
+	for i := range daemonSets.Items {
+		errs = append(errs, s.reconcileDaemonSet(ctx, &daemonSets.Items[i], pods.Items))
+	}
+
+	for i := range replicaSets.Items {
+		errs = append(errs, s.reconcileReplicaSet(ctx, &replicaSets.Items[i], owners, pods.Items))
+	}
+
+	for i := range jobs.Items {
+		errs = append(errs, s.reconcileJob(ctx, &jobs.Items[i], owners, pods.Items))
+	}
+
+	for i := range pods.Items {
+		errs = append(errs, s.reconcilePod(ctx, &pods.Items[i], owners))
+	}
+
+	s.forgetDeletedPods(pods.Items)
+
+	return errors.Join(errs...)
+}
+
+func (s *Simulator) reconcileDeployment(ctx context.Context, deployment *appsv1.Deployment,
+	replicaSets []appsv1.ReplicaSet, pods []corev1.Pod,
+) error {
+	if deployment.DeletionTimestamp != nil {
+		return nil
+	}
+
+	replicas := replicasOf(deployment.Spec.Replicas)
+	hash := templateHash(&deployment.Spec.Template)
+	newName := deployment.Name + "-" + hash
+	replicaSetClient := s.clientSet.AppsV1().ReplicaSets(deployment.Namespace)
+	status := appsv1.DeploymentStatus{ObservedGeneration: deployment.Generation}
+	found := false
+
+	for i := range replicaSets {
+		replicaSet := &replicaSets[i]
+		if !isControlledBy(replicaSet, "Deployment", deployment) {
+			continue
+		}
+
+		desired := int32(0)
+		active, ready := s.countPods(podsControlledBy(pods, "ReplicaSet", replicaSet))
+		status.Replicas += active
+		status.ReadyReplicas += ready
+
+		if replicaSet.Name == newName {
+			found = true
+			desired = replicas
+			status.UpdatedReplicas = active
+		}
+
+		if replicasOf(replicaSet.Spec.Replicas) != desired {
+			scaled := replicaSet.DeepCopy()
+			scaled.Spec.Replicas = &desired
+
+			if _, err := replicaSetClient.Update(ctx, scaled, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !found {
+		_, err := replicaSetClient.Create(ctx, newReplicaSet(deployment, hash, replicas), metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	status.AvailableReplicas = status.ReadyReplicas
+	status.UnavailableReplicas = max(replicas-status.AvailableReplicas, 0)
+	status.Conditions = deploymentConditions(replicas, status)
+
+	if equality.Semantic.DeepEqual(status, deployment.Status) {
+		return nil
+	}
+
+	return patchStatus(ctx, s.clientSet.AppsV1().Deployments(deployment.Namespace).Patch, deployment.Name, status)
+}
+
+func (s *Simulator) reconcileReplicaSet(ctx context.Context, replicaSet *appsv1.ReplicaSet,
+	owners map[ownerKey]bool, pods []corev1.Pod,
+) error {
+	if isOrphaned(replicaSet, owners) {
+		err := s.clientSet.AppsV1().ReplicaSets(replicaSet.Namespace).Delete(ctx, replicaSet.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	var active []*corev1.Pod
+
+	for _, pod := range podsControlledBy(pods, "ReplicaSet", replicaSet) {
+		if s.isActive(pod) {
+			active = append(active, pod)
+		}
+	}
+
+	replicas := replicasOf(replicaSet.Spec.Replicas)
+
+	for i := int32(len(active)); i < replicas; i++ {
+		if err := s.createPod(ctx, replicaSet.Namespace, replicaSet.Name+"-"+utilrand.String(5),
+			"ReplicaSet", replicaSet, &replicaSet.Spec.Template, nil); err != nil {
+			return err
+		}
+	}
+
+	for i := replicas; i < int32(len(active)); i++ {
+		s.deletePod(ctx, active[i])
+	}
+
+	activeCount, ready := s.countPods(active)
+	status := appsv1.ReplicaSetStatus{
+		Replicas:             activeCount,
+		FullyLabeledReplicas: activeCount,
+		ReadyReplicas:        ready,
+		AvailableReplicas:    ready,
+		ObservedGeneration:   replicaSet.Generation,
+	}
+
+	if equality.Semantic.DeepEqual(status, replicaSet.Status) {
+		return nil
+	}
+
+	return patchStatus(ctx, s.clientSet.AppsV1().ReplicaSets(replicaSet.Namespace).Patch, replicaSet.Name, status)
+}
+
+// reconcileStatefulSet creates the pods of a StatefulSet one ordinal at a
+// time, replaces outdated pods from the highest ordinal down and removes pods
+// beyond the desired replica count, like the OrderedReady policy does.
+//
+//nolint:cyclop,gocognit // mirrors the ordered steps of the StatefulSet controller
+func (s *Simulator) reconcileStatefulSet(ctx context.Context, statefulSet *appsv1.StatefulSet,
+	pods []corev1.Pod,
+) error {
+	if statefulSet.DeletionTimestamp != nil {
+		return nil
+	}
+
+	replicas := replicasOf(statefulSet.Spec.Replicas)
+	revision := statefulSet.Name + "-" + templateHash(&statefulSet.Spec.Template)
+	byOrdinal := map[int32]*corev1.Pod{}
+	allReady := true
+
+	for _, pod := range podsControlledBy(pods, "StatefulSet", statefulSet) {
+		ordinal, ok := podOrdinal(statefulSet.Name, pod.Name)
+		if !ok {
+			continue
+		}
+
+		if ordinal >= replicas {
+			s.deletePod(ctx, pod)
+
+			continue
+		}
+
+		byOrdinal[ordinal] = pod
+		allReady = allReady && s.isActive(pod) && isPodReady(pod)
+	}
+
+	for ordinal := range replicas {
+		pod, exists := byOrdinal[ordinal]
+		if !exists {
+			err := s.createPod(ctx, statefulSet.Namespace, fmt.Sprintf("%s-%d", statefulSet.Name, ordinal),
+				"StatefulSet", statefulSet, &statefulSet.Spec.Template, map[string]string{
+					controllerRevisionHashLabel: revision,
+					statefulSetPodNameLabel:     fmt.Sprintf("%s-%d", statefulSet.Name, ordinal),
+				})
+			if err != nil {
+				return err
+			}
+
+			allReady = false
+
+			break
+		}
+
+		if !s.isActive(pod) || !isPodReady(pod) {
+			allReady = false
+
+			break
+		}
+	}
+
+	if allReady && int32(len(byOrdinal)) == replicas {
+		for ordinal := replicas - 1; ordinal >= 0; ordinal-- {
+			if pod := byOrdinal[ordinal]; pod.Labels[controllerRevisionHashLabel] != revision {
+				s.deletePod(ctx, pod)
+
+				break
+			}
+		}
+	}
+
+	status := appsv1.StatefulSetStatus{
+		ObservedGeneration: statefulSet.Generation,
+		UpdateRevision:     revision,
+		CurrentRevision:    statefulSet.Status.CurrentRevision,
+	}
+
+	for _, pod := range byOrdinal {
+		if !s.isActive(pod) {
+			continue
+		}
+
+		status.Replicas++
+
+		if isPodReady(pod) {
+			status.ReadyReplicas++
+			status.AvailableReplicas++
+		}
+
+		if pod.Labels[controllerRevisionHashLabel] == revision {
+			status.UpdatedReplicas++
+		}
+	}
+
+	if status.CurrentRevision == "" || status.UpdatedReplicas == replicas {
+		status.CurrentRevision = revision
+	}
+
+	for _, pod := range byOrdinal {
+		if s.isActive(pod) && pod.Labels[controllerRevisionHashLabel] == status.CurrentRevision {
+			status.CurrentReplicas++
+		}
+	}
+
+	if equality.Semantic.DeepEqual(status, statefulSet.Status) {
+		return nil
+	}
+
+	return patchStatus(ctx, s.clientSet.AppsV1().StatefulSets(statefulSet.Namespace).Patch, statefulSet.Name, status)
+}
+
+// reconcileDaemonSet runs one pod per simulated node and, once all of them are
+// ready, replaces outdated pods one at a time like a rolling update with
+// maxUnavailable 1 does.
+func (s *Simulator) reconcileDaemonSet(ctx context.Context, daemonSet *appsv1.DaemonSet,
+	pods []corev1.Pod,
+) error {
+	if daemonSet.DeletionTimestamp != nil {
+		return nil
+	}
+
+	revision := daemonSet.Name + "-" + templateHash(&daemonSet.Spec.Template)
+	byNode := map[string]*corev1.Pod{}
+	terminating := map[string]bool{}
+
+	for _, pod := range podsControlledBy(pods, "DaemonSet", daemonSet) {
+		switch _, exists := byNode[pod.Spec.NodeName]; {
+		case !s.isActive(pod):
+			terminating[pod.Spec.NodeName] = true
+		case exists || !s.isNode(pod.Spec.NodeName):
+			s.deletePod(ctx, pod)
+		default:
+			byNode[pod.Spec.NodeName] = pod
+		}
+	}
+
+	status := appsv1.DaemonSetStatus{
+		ObservedGeneration:     daemonSet.Generation,
+		DesiredNumberScheduled: s.nodes,
+	}
+	allReady := true
+
+	for i := range s.nodes {
+		node := nodeName(i)
+
+		pod, exists := byNode[node]
+		if !exists {
+			allReady = false
+
+			if terminating[node] {
+				continue
+			}
+
+			template := daemonSet.Spec.Template.DeepCopy()
+			template.Spec.NodeName = node
+
+			if err := s.createPod(ctx, daemonSet.Namespace, daemonSet.Name+"-"+utilrand.String(5),
+				"DaemonSet", daemonSet, template, map[string]string{controllerRevisionHashLabel: revision}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		status.CurrentNumberScheduled++
+
+		if isPodReady(pod) {
+			status.NumberReady++
+			status.NumberAvailable++
+		} else {
+			allReady = false
+		}
+
+		if pod.Labels[controllerRevisionHashLabel] == revision {
+			status.UpdatedNumberScheduled++
+		}
+	}
+
+	if allReady {
+		for i := range s.nodes {
+			if pod := byNode[nodeName(i)]; pod.Labels[controllerRevisionHashLabel] != revision {
+				s.deletePod(ctx, pod)
+
+				break
+			}
+		}
+	}
+
+	status.NumberUnavailable = status.DesiredNumberScheduled - status.NumberAvailable
+
+	if equality.Semantic.DeepEqual(status, daemonSet.Status) {
+		return nil
+	}
+
+	return patchStatus(ctx, s.clientSet.AppsV1().DaemonSets(daemonSet.Namespace).Patch, daemonSet.Name, status)
+}
+
+// reconcileJob runs the pods of a Job until the requested number of
+// completions succeeded or more pods failed than the backoff limit allows.
+func (s *Simulator) reconcileJob(ctx context.Context, job *batchv1.Job, owners map[ownerKey]bool,
+	pods []corev1.Pod,
+) error {
+	if isOrphaned(job, owners) {
+		background := metav1.DeletePropagationBackground
+
+		err := s.clientSet.BatchV1().Jobs(job.Namespace).Delete(ctx, job.Name,
+			metav1.DeleteOptions{PropagationPolicy: &background})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	if job.DeletionTimestamp != nil || isJobFinished(job) {
+		return nil
+	}
+
+	var active []*corev1.Pod
+
+	status := batchv1.JobStatus{StartTime: job.Status.StartTime}
+	if status.StartTime == nil {
+		now := metav1.Now().Rfc3339Copy()
+		status.StartTime = &now
+	}
+
+	for _, pod := range podsControlledBy(pods, "Job", job) {
+		switch {
+		case pod.Status.Phase == corev1.PodSucceeded:
+			status.Succeeded++
+		case pod.Status.Phase == corev1.PodFailed:
+			status.Failed++
+		case s.isActive(pod):
+			active = append(active, pod)
+		}
+	}
+
+	completions := replicasOf(job.Spec.Completions)
+	backoffLimit := int32(defaultBackoffLimit)
+
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
+	}
+
+	switch {
+	case status.Succeeded >= completions:
+		status.Conditions = jobConditions(batchv1.JobComplete, "Completed", "")
+		status.CompletionTime = status.Conditions[0].LastTransitionTime.DeepCopy()
+	case status.Failed > backoffLimit:
+		status.Conditions = jobConditions(batchv1.JobFailed, "BackoffLimitExceeded",
+			"Job has reached the specified backoff limit")
+	default:
+		missing := min(replicasOf(job.Spec.Parallelism), completions-status.Succeeded) - int32(len(active))
+		for range missing {
+			if err := s.createPod(ctx, job.Namespace, job.Name+"-"+utilrand.String(5),
+				"Job", job, &job.Spec.Template, nil); err != nil {
+				return err
+			}
+		}
+
+		_, ready := s.countPods(active)
+		status.Active = int32(len(active)) + max(missing, 0)
+		status.Ready = &ready
+	}
+
+	if status.Conditions != nil {
+		for _, pod := range active {
+			s.deletePod(ctx, pod)
+		}
+	}
+
+	if equality.Semantic.DeepEqual(status, job.Status) {
+		return nil
+	}
+
+	return patchStatus(ctx, s.clientSet.BatchV1().Jobs(job.Namespace).Patch, job.Name, status)
+}
+
+func (s *Simulator) reconcilePod(ctx context.Context, pod *corev1.Pod, owners map[ownerKey]bool) error {
+	if isOrphaned(pod, owners) {
+		s.deletePod(ctx, pod)
+	}
+
+	if deletionStart, deleting := s.deletionStart(pod); deleting {
+		// The containers are stopped first, so that their final state can be
+		// observed before the pod is gone.
+		if status, stopped := s.stoppedStatus(pod, deletionStart); stopped {
+			return patchStatus(ctx, s.clientSet.CoreV1().Pods(pod.Namespace).Patch, pod.Name, status)
+		}
+
+		if time.Since(deletionStart) < s.podDeletionDelay {
+			return nil
+		}
+
+		err := s.clientSet.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name,
+			metav1.DeleteOptions{GracePeriodSeconds: new(int64)})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	status := s.podStatus(pod, time.Since(s.seen(pod)))
+	if equality.Semantic.DeepEqual(status, pod.Status) {
+		return nil
+	}
+
+	return patchStatus(ctx, s.clientSet.CoreV1().Pods(pod.Namespace).Patch, pod.Name, status)
+}
+
+// stoppedStatus returns the status of pod after its running containers received
+// SIGTERM, and whether any container was running. Containers exit right away
+// unless they ignore SIGTERM, then they are killed when the grace period ends.
+func (s *Simulator) stoppedStatus(pod *corev1.Pod, deletionStart time.Time) (corev1.PodStatus, bool) {
+	gracePeriod := time.Duration(terminationGracePeriodOf(pod)) * time.Second
+	requested := metav1.NewTime(deletionStart).Rfc3339Copy()
+
+	if pod.DeletionTimestamp != nil {
+		requested = metav1.NewTime(pod.DeletionTimestamp.Add(-gracePeriod))
+	}
+
+	status := *pod.Status.DeepCopy()
+	stopped := false
+
+	for i := range status.ContainerStatuses {
+		containerStatus := &status.ContainerStatuses[i]
+		if containerStatus.State.Running == nil {
+			continue
+		}
+
+		terminated := &corev1.ContainerStateTerminated{
+			Reason:     "Completed",
+			StartedAt:  containerStatus.State.Running.StartedAt,
+			FinishedAt: requested,
+		}
+
+		s.mu.Lock()
+		terminated.Message = s.terminationMessages[containerStatus.Image]
+
+		if s.sigtermIgnored[containerStatus.Image] {
+			terminated.ExitCode = exitCodeSIGKILL
+			terminated.Reason = ReasonError
+			terminated.FinishedAt = metav1.NewTime(requested.Add(gracePeriod))
+		}
+		s.mu.Unlock()
+
+		containerStatus.State = corev1.ContainerState{Terminated: terminated}
+		containerStatus.Ready = false
+		containerStatus.Started = boolPtr(false)
+		stopped = true
+	}
+
+	if !stopped {
+		return status, false
+	}
+
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == corev1.PodReady || status.Conditions[i].Type == corev1.ContainersReady {
+			status.Conditions[i].Status = corev1.ConditionFalse
+		}
+	}
+
+	return status, true
+}
+
+// podStatus computes the status a kubelet would report for pod after it has
+// been known for the given time. Timestamps are derived from the creation
+// time so that the result is stable between reconciles.
+func (s *Simulator) podStatus(pod *corev1.Pod, elapsed time.Duration) corev1.PodStatus {
+	created := pod.CreationTimestamp.Rfc3339Copy()
+	status := corev1.PodStatus{
+		Phase:     corev1.PodRunning,
+		StartTime: &created,
+		Conditions: []corev1.PodCondition{
+			{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		},
+	}
+
+	allReady := true
+	allTerminated := true
+
+	for _, container := range pod.Spec.Containers {
+		containerStatus := s.containerStatus(pod, container, elapsed)
+
+		switch {
+		case containerStatus.State.Terminated != nil:
+			if containerStatus.State.Terminated.ExitCode != 0 {
+				status.Phase = corev1.PodFailed
+			}
+		case containerStatus.Started == nil && containerStatus.RestartCount == 0:
+			status.Phase = corev1.PodPending
+			allTerminated = false
+		default:
+			allTerminated = false
+		}
+
+		allReady = allReady && containerStatus.Ready
+		status.ContainerStatuses = append(status.ContainerStatuses, containerStatus)
+	}
+
+	if allTerminated && status.Phase != corev1.PodFailed {
+		status.Phase = corev1.PodSucceeded
+	}
+
+	ready := corev1.ConditionFalse
+	if allReady {
+		ready = corev1.ConditionTrue
+	}
+
+	status.Conditions = append(status.Conditions,
+		corev1.PodCondition{Type: corev1.PodInitialized, Status: corev1.ConditionTrue},
+		corev1.PodCondition{Type: corev1.ContainersReady, Status: ready},
+		corev1.PodCondition{Type: corev1.PodReady, Status: ready},
+	)
+
+	return status
+}
+
+// containerStatus computes the status of one container of pod. Containers of
+// pods that never restart, i.e. pods of Jobs, run to completion right after
+// they have started.
+func (s *Simulator) containerStatus(pod *corev1.Pod, container corev1.Container,
+	elapsed time.Duration,
+) corev1.ContainerStatus {
+	created := pod.CreationTimestamp.Rfc3339Copy()
+	started := metav1.NewTime(created.Add(s.podReadyDelay)).Rfc3339Copy()
+	runsToCompletion := pod.Spec.RestartPolicy == corev1.RestartPolicyNever ||
+		pod.Spec.RestartPolicy == corev1.RestartPolicyOnFailure
+	containerStatus := corev1.ContainerStatus{
+		Name:  container.Name,
+		Image: container.Image,
+	}
+
+	reason := s.imageFailure(container.Image)
+	if reason == ReasonError && !runsToCompletion {
+		reason = ReasonCrashLoopBackOff
+	}
+
+	switch {
+	case elapsed < s.podReadyDelay:
+		containerStatus.State.Waiting = &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}
+	case runsToCompletion && (reason == "" || reason == ReasonError):
+		containerStatus.Started = boolPtr(false)
+		containerStatus.State.Terminated = &corev1.ContainerStateTerminated{
+			Reason:     "Completed",
+			StartedAt:  started,
+			FinishedAt: started,
+		}
+
+		if reason == ReasonError {
+			containerStatus.State.Terminated.ExitCode = 1
+			containerStatus.State.Terminated.Reason = ReasonError
+		}
+	case reason == ReasonCrashLoopBackOff:
+		containerStatus.RestartCount = int32((elapsed-s.podReadyDelay)/time.Second) + 1
+		containerStatus.State.Waiting = &corev1.ContainerStateWaiting{
+			Reason:  ReasonCrashLoopBackOff,
+			Message: fmt.Sprintf("back-off restarting failed container %s", container.Name),
+		}
+		containerStatus.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{
+			ExitCode: 1,
+			Reason:   ReasonError,
+		}
+	case reason != "":
+		containerStatus.State.Waiting = &corev1.ContainerStateWaiting{
+			Reason:  reason,
+			Message: fmt.Sprintf("simulated %s for image %q", reason, container.Image),
+		}
+	default:
+		containerStatus.Ready = true
+		containerStatus.Started = boolPtr(true)
+		containerStatus.State.Running = &corev1.ContainerStateRunning{StartedAt: started}
+	}
+
+	return containerStatus
+}
+
+func (s *Simulator) createPod(ctx context.Context, namespace, name, ownerKind string, owner metav1.Object,
+	template *corev1.PodTemplateSpec, extraLabels map[string]string,
+) error {
+	labels := maps.Clone(template.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	maps.Copy(labels, extraLabels)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			Labels:          labels,
+			Annotations:     maps.Clone(template.Annotations),
+			OwnerReferences: []metav1.OwnerReference{controllerRef(ownerKind, owner)},
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+
+	_, err := s.clientSet.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
+// deletePod starts the deletion of pod. The pod is marked as terminating, its
+// containers are stopped by the next reconcile and it is removed once the
+// deletion delay has passed.
+func (s *Simulator) deletePod(ctx context.Context, pod *corev1.Pod) {
+	s.mu.Lock()
+	if _, exists := s.deleting[podKey(pod)]; !exists {
+		s.deleting[podKey(pod)] = time.Now()
+	}
+	s.mu.Unlock()
+
+	if pod.DeletionTimestamp != nil {
+		return
+	}
+
+	gracePeriod := terminationGracePeriodOf(pod)
+	deletionTimestamp := metav1.NewTime(time.Now().Add(time.Duration(gracePeriod) * time.Second)).Rfc3339Copy()
+
+	data, err := json.Marshal([]map[string]any{
+		{"op": "add", "path": "/metadata/deletionTimestamp", "value": deletionTimestamp},
+		{"op": "add", "path": "/metadata/deletionGracePeriodSeconds", "value": gracePeriod},
+	})
+	if err != nil {
+		return
+	}
+
+	// Best effort: a real API server ignores this patch, the pod is then
+	// deleted after the delay regardless.
+	_, _ = s.clientSet.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.JSONPatchType, data,
+		metav1.PatchOptions{})
+}
+
+func (s *Simulator) deletionStart(pod *corev1.Pod) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if start, exists := s.deleting[podKey(pod)]; exists {
+		return start, true
+	}
+
+	if pod.DeletionTimestamp != nil {
+		s.deleting[podKey(pod)] = time.Now()
+
+		return s.deleting[podKey(pod)], true
+	}
+
+	return time.Time{}, false
+}
+
+func (s *Simulator) isActive(pod *corev1.Pod) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, deleting := s.deleting[podKey(pod)]
+
+	return pod.DeletionTimestamp == nil && !deleting
+}
+
+func (s *Simulator) countPods(pods []*corev1.Pod) (int32, int32) {
+	var active, ready int32
+
+	for _, pod := range pods {
+		if !s.isActive(pod) {
+			continue
+		}
+
+		active++
+
+		if isPodReady(pod) {
+			ready++
+		}
+	}
+
+	return active, ready
+}
+
+func (s *Simulator) seen(pod *corev1.Pod) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := podKey(pod)
+	if _, exists := s.firstSeen[key]; !exists {
+		s.firstSeen[key] = time.Now()
+	}
+
+	return s.firstSeen[key]
+}
+
+func (s *Simulator) imageFailure(image string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.imageFailures[image]
+}
+
+// isNode reports whether name is one of the simulated nodes.
+func (s *Simulator) isNode(name string) bool {
+	for i := range s.nodes {
+		if nodeName(i) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forgetDeletedPods drops the bookkeeping of pods that no longer exist.
+func (s *Simulator) forgetDeletedPods(pods []corev1.Pod) {
+	existing := make(map[string]bool, len(pods))
+	for i := range pods {
+		existing[podKey(&pods[i])] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.firstSeen {
+		if !existing[key] {
+			delete(s.firstSeen, key)
+		}
+	}
+
+	for key := range s.deleting {
+		if !existing[key] {
+			delete(s.deleting, key)
+		}
+	}
+}
+
+func newReplicaSet(deployment *appsv1.Deployment, hash string, replicas int32) *appsv1.ReplicaSet {
+	template := deployment.Spec.Template.DeepCopy()
+	template.Labels = maps.Clone(template.Labels)
+
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+
+	template.Labels[podTemplateHashLabel] = hash
+
+	selector := deployment.Spec.Selector.DeepCopy()
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+
+	if selector.MatchLabels == nil {
+		selector.MatchLabels = map[string]string{}
+	}
+
+	selector.MatchLabels[podTemplateHashLabel] = hash
+
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            deployment.Name + "-" + hash,
+			Namespace:       deployment.Namespace,
+			Labels:          template.Labels,
+			OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", deployment)},
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: &replicas,
+			Selector: selector,
+			Template: *template,
+		},
+	}
+}
+
+func deploymentConditions(replicas int32, status appsv1.DeploymentStatus) []appsv1.DeploymentCondition {
+	available := appsv1.DeploymentCondition{
+		Type:   appsv1.DeploymentAvailable,
+		Status: corev1.ConditionTrue,
+		Reason: "MinimumReplicasAvailable",
+	}
+	if status.AvailableReplicas < replicas {
+		available.Status = corev1.ConditionFalse
+		available.Reason = "MinimumReplicasUnavailable"
+	}
+
+	progressing := appsv1.DeploymentCondition{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionTrue,
+		Reason: "NewReplicaSetAvailable",
+	}
+	if status.UpdatedReplicas < replicas || status.Replicas > status.UpdatedReplicas {
+		progressing.Reason = "ReplicaSetUpdated"
+	}
+
+	return []appsv1.DeploymentCondition{available, progressing}
+}
+
+func patchStatus[T any](ctx context.Context, patch statusPatchFunc[T], name string, status any) error {
+	data, err := json.Marshal([]map[string]any{{"op": "add", "path": "/status", "value": status}})
+	if err != nil {
+		return err
+	}
+
+	_, err = patch(ctx, name, types.JSONPatchType, data, metav1.PatchOptions{}, "status")
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func controllerRef(kind string, owner metav1.Object) metav1.OwnerReference {
+	apiVersion := appsv1.SchemeGroupVersion.String()
+	if kind == "Job" {
+		apiVersion = batchv1.SchemeGroupVersion.String()
+	}
+
+	return metav1.OwnerReference{
+		APIVersion:         apiVersion,
+		Kind:               kind,
+		Name:               owner.GetName(),
+		UID:                owner.GetUID(),
+		Controller:         boolPtr(true),
+		BlockOwnerDeletion: boolPtr(true),
+	}
+}
+
+func ownerKeyOf(kind string, obj metav1.Object) ownerKey {
+	return ownerKey{kind: kind, namespace: obj.GetNamespace(), name: obj.GetName(), uid: obj.GetUID()}
+}
+
+// isOrphaned reports whether obj is controlled by a workload the simulator
+// manages that no longer exists, i.e. whether the garbage collector would
+// delete it.
+func isOrphaned(obj metav1.Object, owners map[ownerKey]bool) bool {
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil {
+		return false
+	}
+
+	switch {
+	case ref.APIVersion == appsv1.SchemeGroupVersion.String() &&
+		(ref.Kind == "Deployment" || ref.Kind == "StatefulSet" || ref.Kind == "DaemonSet" ||
+			ref.Kind == "ReplicaSet"),
+		ref.APIVersion == batchv1.SchemeGroupVersion.String() && (ref.Kind == "Job" || ref.Kind == "CronJob"):
+		return !owners[ownerKey{kind: ref.Kind, namespace: obj.GetNamespace(), name: ref.Name, uid: ref.UID}]
+	}
+
+	return false
+}
+
+func isControlledBy(obj metav1.Object, kind string, owner metav1.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+
+	return ref != nil && ref.Kind == kind && ref.Name == owner.GetName() && ref.UID == owner.GetUID() &&
+		obj.GetNamespace() == owner.GetNamespace()
+}
+
+func podsControlledBy(pods []corev1.Pod, kind string, owner metav1.Object) []*corev1.Pod {
+	var owned []*corev1.Pod
+
+	for i := range pods {
+		if isControlledBy(&pods[i], kind, owner) {
+			owned = append(owned, &pods[i])
+		}
+	}
+
+	return owned
+}
+
+func jobConditions(conditionType batchv1.JobConditionType, reason, message string) []batchv1.JobCondition {
+	now := metav1.Now().Rfc3339Copy()
+
+	return []batchv1.JobCondition{{
+		Type:               conditionType,
+		Status:             corev1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	}}
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func podOrdinal(statefulSetName, podName string) (int32, bool) {
+	if len(podName) <= len(statefulSetName)+1 || podName[:len(statefulSetName)+1] != statefulSetName+"-" {
+		return 0, false
+	}
+
+	ordinal, err := strconv.ParseInt(podName[len(statefulSetName)+1:], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return int32(ordinal), true
+}
+
+func nodeName(index int32) string {
+	return fmt.Sprintf("node-%d", index)
+}
+
+// terminationGracePeriodOf returns the grace period pod is deleted with.
+func terminationGracePeriodOf(pod *corev1.Pod) int64 {
+	switch {
+	case pod.DeletionGracePeriodSeconds != nil:
+		return *pod.DeletionGracePeriodSeconds
+	case pod.Spec.TerminationGracePeriodSeconds != nil:
+		return *pod.Spec.TerminationGracePeriodSeconds
+	default:
+		return defaultTerminationGracePeriod
+	}
+}
+
+func podKey(pod *corev1.Pod) string {
+	return pod.Namespace + "/" + pod.Name + "/" + string(pod.UID)
+}
+
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
+
+// templateHash returns a short, stable hash of a pod template, used like the
+// pod-template-hash and controller-revision-hash of the real controllers.
+func templateHash(template *corev1.PodTemplateSpec) string {
+	hasher := fnv.New32a()
+
+	data, _ := json.Marshal(template)
+	_, _ = hasher.Write(data)
+
+	return utilrand.SafeEncodeString(strconv.FormatUint(uint64(hasher.Sum32()), 10))
+}
@@ -0,0 +1,246 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ReplicasOption returns a ResourceOption that sets the replica count of
+// Deployments and StatefulSets.
+func ReplicasOption(replicas int32) ResourceOption {
+	return func(obj runtime.Object) {
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			o.Spec.Replicas = &replicas
+		case *appsv1.StatefulSet:
+			o.Spec.Replicas = &replicas
+		}
+	}
+}
+
+func TestSimulatorDeployment(t *testing.T) {
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithResourceOption(ReplicasOption(2)).
+		WithDeployment("deployment-sim-1").
+		WithConfigMap("config-map-sim-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replicaSets, err := resources.TestClients.ClientSet.AppsV1().ReplicaSets(resources.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "app=deployment-sim-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(replicaSets.Items) != 1 {
+		t.Errorf("Expected 1 replicaset, got %d", len(replicaSets.Items))
+	}
+
+	pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "app=deployment-sim-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods.Items) != 2 {
+		t.Fatalf("Expected 2 pods, got %d", len(pods.Items))
+	}
+
+	if pods.Items[0].Status.Phase != corev1.PodRunning {
+		t.Errorf("Expected pod to be running, got %s", pods.Items[0].Status.Phase)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimulatorStatefulSet(t *testing.T) {
+	resources, err := NewFake(t, context.Background()).
+		WithResourceOption(ReplicasOption(3)).
+		WithStatefulSet("statefulset-sim-1").
+		WithSecret("secret-sim-1").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ordinal := range 3 {
+		name := fmt.Sprintf("statefulset-sim-1-%d", ordinal)
+
+		_, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).Get(
+			context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("Expected pod %s to exist: %v", name, err)
+		}
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimulatorImageFailure(t *testing.T) {
+	invalidImage := "invalid-image-name-that-does-not-exist:latest"
+
+	resources, err := NewFake(t, context.Background(),
+		WithSimulator(WithImageFailure(invalidImage, "ErrImagePull"))).
+		WithResourceOption(InvalidImageOption()).
+		WithDeployment("deployment-sim-invalid-image").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(300 * time.Millisecond)
+	if err == nil {
+		t.Fatal("Expected Wait to fail for a deployment with an invalid image")
+	}
+
+	pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "app=deployment-sim-invalid-image"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods.Items) != 1 || len(pods.Items[0].Status.ContainerStatuses) != 1 {
+		t.Fatalf("Expected 1 pod with 1 container status, got %v", pods.Items)
+	}
+
+	waiting := pods.Items[0].Status.ContainerStatuses[0].State.Waiting
+	if waiting == nil || waiting.Reason != "ErrImagePull" {
+		t.Errorf("Expected container to wait with ErrImagePull, got %v", waiting)
+	}
+}
+
+func TestSimulatorDelays(t *testing.T) {
+	tests := []struct {
+		name                 string
+		option               SimulatorOption
+		minExpectedDur       time.Duration
+		minExpectedDeleteDur time.Duration
+	}{
+		{
+			name:           "Pod ready delay",
+			option:         WithPodReadyDelay(300 * time.Millisecond),
+			minExpectedDur: 300 * time.Millisecond,
+		},
+		{
+			name:                 "Pod deletion delay",
+			option:               WithPodDeletionDelay(300 * time.Millisecond),
+			minExpectedDeleteDur: 300 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources, err := NewFake(t, context.Background(), WithSimulator(tt.option)).
+				WithDeployment("deployment-sim-delay").
+				Create()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+
+			err = resources.Wait(2 * time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if duration := time.Since(start); duration < tt.minExpectedDur {
+				t.Errorf("Expected Wait to take at least %v, got %v", tt.minExpectedDur, duration)
+			}
+
+			start = time.Now()
+
+			err = resources.DeleteAndWait(2 * time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if duration := time.Since(start); duration < tt.minExpectedDeleteDur {
+				t.Errorf("Expected DeleteAndWait to take at least %v, got %v", tt.minExpectedDeleteDur, duration)
+			}
+		})
+	}
+}
+
+func TestSimulatorRollout(t *testing.T) {
+	resources, err := NewFake(t, context.Background()).
+		WithResourceOption(ReplicasOption(2)).
+		WithDeployment("deployment-sim-rollout").
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The status written by the simulator changed the resourceVersion.
+	err = resources.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := resources.Deployments[0]
+	deployment.Spec.Template.Spec.Containers[0].Image = "busybox:stable"
+
+	_, err = resources.Update(deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = waitForCondition(2*time.Second, func() bool {
+		pods, err := resources.TestClients.ClientSet.CoreV1().Pods(resources.Namespace).List(
+			context.Background(), metav1.ListOptions{LabelSelector: "app=deployment-sim-rollout"})
+		if err != nil || len(pods.Items) != 2 {
+			return false
+		}
+
+		for _, pod := range pods.Items {
+			if pod.Spec.Containers[0].Image != "busybox:stable" || !isPodReady(&pod) {
+				return false
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		t.Errorf("Expected all pods to run the new image: %v", err)
+	}
+}
+
+func waitForCondition(timeout time.Duration, condition func() bool) error {
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return nil
+		}
+	}
+
+	return context.DeadlineExceeded
+}
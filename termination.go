@@ -0,0 +1,283 @@
+package k8stest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// terminationSettlePeriod is how long Stop waits for further pod events
+	// before it considers all terminations recorded.
+	terminationSettlePeriod = 200 * time.Millisecond
+	// terminationPollInterval is how often Stop checks whether all terminating
+	// pods are gone.
+	terminationPollInterval = 20 * time.Millisecond
+)
+
+// Termination describes a container that terminated because its pod was
+// deleted, e.g. during a rollout or when its workload was deleted.
+// ShutdownDuration is the time from the deletion of the pod to the container
+// exiting.
+type Termination struct {
+	Pod              string
+	Container        string
+	ExitCode         int32
+	Reason           string
+	Message          string
+	ShutdownDuration time.Duration
+}
+
+// KilledBySIGKILL reports whether the container did not exit on SIGTERM and
+// was killed when the termination grace period of its pod ended.
+func (t Termination) KilledBySIGKILL() bool {
+	return t.ExitCode == exitCodeSIGKILL
+}
+
+func (t Termination) String() string {
+	return fmt.Sprintf("container %s of pod %s exited with code %d (%s) after %s: %q",
+		t.Container, t.Pod, t.ExitCode, t.Reason, t.ShutdownDuration, t.Message)
+}
+
+// TerminationRecorder records the containers of the pods of a workload that
+// terminate while their pods are deleted. Create it with RecordTerminations.
+type TerminationRecorder struct {
+	resources *Resources
+	workload  client.Object
+	timeout   time.Duration
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu           sync.Mutex
+	owned        map[types.UID]bool
+	terminating  map[types.UID]bool
+	recorded     map[string]bool
+	terminations []Termination
+	lastEvent    time.Time
+}
+
+// RecordTerminations starts recording the terminated containers of the pods of
+// workload, which is a Deployment, StatefulSet, DaemonSet or Job, see Pods.
+// Trigger a rollout or delete the workload, then call Stop to get the
+// terminations. Recording also stops when the test ends.
+func (r *Resources) RecordTerminations(workload client.Object) (*TerminationRecorder, error) {
+	live, err := liveWorkload(*r.Ctx, r, workload)
+	if err != nil {
+		return nil, err
+	}
+
+	listOptions, ok := selectorListOptions(podSelectorOf(live))
+	if !ok {
+		return nil, fmt.Errorf("failed to record terminations of %s %s: %w", workloadKind(live), live.GetName(),
+			ErrNoPodSelector)
+	}
+
+	ctx, cancel := context.WithCancel(*r.Ctx)
+	pods := r.TestClients.ClientSet.CoreV1().Pods(live.GetNamespace())
+
+	// The watch is opened before the pods are listed, so that no pod created
+	// in between goes unnoticed.
+	watcher, err := pods.Watch(ctx, listOptions)
+	if err != nil {
+		cancel()
+
+		return nil, fmt.Errorf("failed to watch pods of %s %s: %w", workloadKind(live), live.GetName(), err)
+	}
+
+	recorder := &TerminationRecorder{
+		resources:   r,
+		workload:    live,
+		timeout:     r.Timeout,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		owned:       map[types.UID]bool{},
+		terminating: map[types.UID]bool{},
+		recorded:    map[string]bool{},
+	}
+
+	if err := recorder.refreshOwned(ctx); err != nil {
+		watcher.Stop()
+		cancel()
+
+		return nil, err
+	}
+
+	go recorder.run(ctx, watcher, func(ctx context.Context) (watch.Interface, error) {
+		return pods.Watch(ctx, listOptions)
+	})
+
+	if r.t != nil {
+		r.t.Cleanup(cancel)
+	}
+
+	return recorder, nil
+}
+
+// Terminations returns the terminations recorded so far.
+func (t *TerminationRecorder) Terminations() []Termination {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Termination(nil), t.terminations...)
+}
+
+// Stop waits until all pods that were seen terminating are gone and no pod
+// changed for a short while, or the timeout of the Resources expired, then
+// stops recording and returns all terminations.
+func (t *TerminationRecorder) Stop() []Termination {
+	deadline := time.Now().Add(t.timeout)
+
+	ticker := time.NewTicker(terminationPollInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) && !t.settled() {
+		<-ticker.C
+	}
+
+	t.cancel()
+	<-t.done
+
+	return t.Terminations()
+}
+
+// AssertGracefulTermination fails the test if no terminations were recorded
+// or a container was killed with SIGKILL instead of exiting on SIGTERM.
+func AssertGracefulTermination(t *testing.T, terminations []Termination) {
+	t.Helper()
+
+	if len(terminations) == 0 {
+		t.Error("Expected containers to terminate, but none were recorded")
+	}
+
+	for _, termination := range terminations {
+		if termination.KilledBySIGKILL() {
+			t.Errorf("Expected container %s of pod %s to exit on SIGTERM, but it was killed with SIGKILL after %s",
+				termination.Container, termination.Pod, termination.ShutdownDuration)
+		}
+	}
+}
+
+func (t *TerminationRecorder) settled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.terminating) == 0 && time.Since(t.lastEvent) >= terminationSettlePeriod
+}
+
+// run records the events of watcher until ctx is done. When the watch ends,
+// e.g. because the API server closed it, it is opened again.
+func (t *TerminationRecorder) run(ctx context.Context, watcher watch.Interface,
+	reopen func(ctx context.Context) (watch.Interface, error),
+) {
+	defer close(t.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			watcher.Stop()
+
+			return
+		case event, open := <-watcher.ResultChan():
+			if open {
+				t.recordEvent(ctx, event)
+
+				continue
+			}
+
+			watcher.Stop()
+
+			var err error
+
+			if watcher, err = reopen(ctx); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (t *TerminationRecorder) recordEvent(ctx context.Context, event watch.Event) {
+	pod, ok := event.Object.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	t.lastEvent = time.Now()
+	owned := t.owned[pod.UID]
+	t.mu.Unlock()
+
+	// Pods created after recording started, e.g. by a new ReplicaSet, are
+	// only known after listing the pods of the workload again.
+	if !owned && event.Type != watch.Deleted {
+		if err := t.refreshOwned(ctx); err != nil {
+			return
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.owned[pod.UID] {
+		return
+	}
+
+	if pod.DeletionTimestamp != nil {
+		t.terminating[pod.UID] = true
+		t.recordContainers(pod)
+	}
+
+	if event.Type == watch.Deleted {
+		delete(t.terminating, pod.UID)
+		delete(t.owned, pod.UID)
+	}
+}
+
+// recordContainers records the terminated containers of the terminating pod
+// that were not recorded yet.
+func (t *TerminationRecorder) recordContainers(pod *corev1.Pod) {
+	gracePeriod := time.Duration(terminationGracePeriodOf(pod)) * time.Second
+	requested := pod.DeletionTimestamp.Add(-gracePeriod)
+
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		key := string(pod.UID) + "/" + status.Name
+
+		if terminated == nil || t.recorded[key] {
+			continue
+		}
+
+		t.recorded[key] = true
+		t.terminations = append(t.terminations, Termination{
+			Pod:              pod.Name,
+			Container:        status.Name,
+			ExitCode:         terminated.ExitCode,
+			Reason:           terminated.Reason,
+			Message:          terminated.Message,
+			ShutdownDuration: max(terminated.FinishedAt.Sub(requested), 0),
+		})
+	}
+}
+
+// refreshOwned lists the pods of the workload and remembers them as owned.
+func (t *TerminationRecorder) refreshOwned(ctx context.Context) error {
+	pods, err := t.resources.listPods(ctx, t.workload)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range pods {
+		t.owned[pods[i].UID] = true
+	}
+
+	return nil
+}
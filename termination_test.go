@@ -0,0 +1,95 @@
+package k8stest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecordTerminationsDuringRollout(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithRolloutWait(), WithSimulator(WithTerminationMessage("busybox:latest", "Terminated by SIGTERM"))).
+		WithDeployment("deployment-termination-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := resources.RecordTerminations(resources.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resources.PatchDeploymentImage("deployment-termination-1", "noop-container", "busybox:stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terminations := recorder.Stop()
+
+	AssertGracefulTermination(t, terminations)
+
+	if len(terminations) != 1 {
+		t.Fatalf("Expected the container of the old pod to terminate, got %v", terminations)
+	}
+
+	termination := terminations[0]
+
+	if termination.Container != "noop-container" || termination.ExitCode != 0 ||
+		termination.Message != "Terminated by SIGTERM" {
+		t.Errorf("Expected a clean exit with the termination message, got %s", termination)
+	}
+}
+
+func TestRecordTerminationsSIGKILL(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients(), WithTestNamespace(),
+		WithSimulator(WithSIGTERMIgnored("busybox:latest"))).
+		WithStatefulSet("statefulset-termination-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := resources.RecordTerminations(resources.StatefulSets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terminations := recorder.Stop()
+	if len(terminations) != 1 {
+		t.Fatalf("Expected the container of the pod to terminate, got %v", terminations)
+	}
+
+	termination := terminations[0]
+
+	if !termination.KilledBySIGKILL() {
+		t.Errorf("Expected the container to be killed with SIGKILL, got %s", termination)
+	}
+
+	if termination.ShutdownDuration != 30*time.Second {
+		t.Errorf("Expected the container to be killed after the default grace period of 30s, got %s",
+			termination.ShutdownDuration)
+	}
+}
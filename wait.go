@@ -0,0 +1,460 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// waitResyncPeriod is how often Wait re-reads all resources that are not
+	// ready yet, in case a watch missed an event.
+	waitResyncPeriod = 5 * time.Second
+	// watchRetryDelay is how long Wait waits before it opens a closed watch
+	// again. The delay doubles with every watch that closes without an event,
+	// up to waitResyncPeriod.
+	watchRetryDelay = 100 * time.Millisecond
+)
+
+// watchFunc opens a watch, like the Watch methods of the clientset.
+type watchFunc func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+
+// waitSource is a watch whose events make Wait re-evaluate the targets with
+// the same source key. With resync set, every event makes all of these
+// targets be read again instead of evaluating the object of the event.
+type waitSource struct {
+	key    string
+	resync bool
+	watch  watchFunc
+}
+
+// waitTarget is a resource Wait waits for. get reads its current state, ready
+// evaluates a state read through get or received through a watch.
+type waitTarget struct {
+	source    string
+	kind      string
+	namespace string
+	name      string
+	get       func(ctx context.Context) (runtime.Object, error)
+	ready     func(obj runtime.Object) (bool, error)
+}
+
+// waitChanges collects the objects received through watches until the wait
+// loop evaluates them. A nil object means the target has to be read again.
+type waitChanges struct {
+	mu      sync.Mutex
+	objects map[string]runtime.Object
+	resync  map[string]bool
+	signal  chan struct{}
+}
+
+func newWaitChanges() *waitChanges {
+	return &waitChanges{
+		objects: map[string]runtime.Object{},
+		resync:  map[string]bool{},
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+func (c *waitChanges) notify() {
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+// changed records the latest state of the object with the given name.
+func (c *waitChanges) changed(source, name string, obj runtime.Object) {
+	c.mu.Lock()
+	c.objects[source+"/"+name] = obj
+	c.mu.Unlock()
+
+	c.notify()
+}
+
+// resyncSource marks all targets of source to be read again, e.g. because its
+// watch was interrupted and events may have been missed.
+func (c *waitChanges) resyncSource(source string) {
+	c.mu.Lock()
+	c.resync[source] = true
+	c.mu.Unlock()
+
+	c.notify()
+}
+
+// take returns and resets the collected changes.
+func (c *waitChanges) take() (map[string]runtime.Object, map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	objects, resync := c.objects, c.resync
+	c.objects, c.resync = map[string]runtime.Object{}, map[string]bool{}
+
+	return objects, resync
+}
+
+// waitFor waits until all targets are ready, using one deadline for all of
+// them. Targets are evaluated when their watch reports a change and every
+// waitResyncPeriod, instead of being polled. An error while evaluating a target
+// ends the wait right away. On timeout, the error lists every target that was
+// not ready together with its diagnostics. If the context of r is cancelled,
+// the error only lists the targets and wraps the error of the context.
+func (r *Resources) waitFor(timeout time.Duration, targets []waitTarget, sources []waitSource) error {
+	pending, err := r.watchTargets(timeout, targets, sources)
+	if err == nil || len(pending) == 0 {
+		return err
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		names := make([]string, 0, len(pending))
+		for _, target := range pending {
+			names = append(names, target.kind+" "+target.name)
+		}
+
+		return fmt.Errorf("failed to wait for %s: %w", strings.Join(names, "; "), err)
+	}
+
+	return &WaitTimeoutError{Resources: r.diagnose(pending), Err: err}
+}
+
+// watchTargets works like waitFor, but on timeout it returns the targets that
+// were not ready together with the error of the context.
+func (r *Resources) watchTargets(timeout time.Duration, targets []waitTarget,
+	sources []waitSource,
+) ([]waitTarget, error) {
+	ctx, cancel := context.WithTimeout(*r.Ctx, timeout)
+	defer cancel()
+
+	changes := newWaitChanges()
+
+	// Watches are opened before the targets are read for the first time, so
+	// that no change in between goes unnoticed.
+	for _, source := range sources {
+		watcher, err := source.watch(ctx, metav1.ListOptions{AllowWatchBookmarks: true})
+		if err != nil {
+			// Without a watch, the periodic resync still notices changes.
+			continue
+		}
+
+		go forwardChanges(ctx, source, watcher, changes)
+	}
+
+	resync := time.NewTicker(waitResyncPeriod)
+	defer resync.Stop()
+
+	pending := targets
+	objects, resyncSources := map[string]runtime.Object{}, map[string]bool{}
+	resyncAll := true
+
+	for {
+		var err error
+
+		pending, err = evaluateTargets(ctx, pending, objects, resyncAll, resyncSources)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pending) == 0 {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return pending, ctx.Err()
+		case <-resync.C:
+			resyncAll = true
+		case <-changes.signal:
+			resyncAll = false
+		}
+
+		objects, resyncSources = changes.take()
+	}
+}
+
+// evaluateTargets returns the targets that are not ready. Only targets that
+// changed, or all of them if resyncAll is set, are evaluated, each in its own
+// goroutine. The errors of all targets that could not be evaluated are joined.
+func evaluateTargets(ctx context.Context, targets []waitTarget, objects map[string]runtime.Object,
+	resyncAll bool, resyncSources map[string]bool,
+) ([]waitTarget, error) {
+	ready := make([]bool, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		obj, changed := objects[target.source+"/"+target.name]
+		if !changed && !resyncAll && !resyncSources[target.source] {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ready[i], errs[i] = target.evaluate(ctx, obj)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("failed to wait for %s %s: %w", target.kind, target.name, errs[i])
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var pending []waitTarget
+
+	for i, target := range targets {
+		if !ready[i] {
+			pending = append(pending, target)
+		}
+	}
+
+	return pending, nil
+}
+
+// evaluate evaluates obj, or the current state of the target if obj is nil.
+func (t waitTarget) evaluate(ctx context.Context, obj runtime.Object) (bool, error) {
+	if obj == nil {
+		var err error
+
+		if obj, err = t.get(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	return t.ready(obj)
+}
+
+// forwardChanges records the events of watcher in changes until ctx is done.
+// When the watch ends, e.g. because the API server closed it, all targets of
+// source are read again, as events may have been missed, and the watch is
+// opened again after a backoff, so that a watch that keeps failing does not
+// hammer the API server.
+func forwardChanges(ctx context.Context, source waitSource, watcher watch.Interface, changes *waitChanges) {
+	backoff := newWatchBackoff()
+
+	for {
+		select {
+		case <-ctx.Done():
+			watcher.Stop()
+
+			return
+		case event, open := <-watcher.ResultChan():
+			if open {
+				recordEvent(source, event, changes)
+
+				backoff = newWatchBackoff()
+
+				continue
+			}
+
+			watcher.Stop()
+			changes.resyncSource(source.key)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff.Step()):
+			}
+
+			var err error
+
+			if watcher, err = source.watch(ctx, metav1.ListOptions{AllowWatchBookmarks: true}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// newWatchBackoff returns the backoff between reopening a closed watch.
+func newWatchBackoff() wait.Backoff {
+	return wait.Backoff{Duration: watchRetryDelay, Factor: 2, Jitter: 0.1, Steps: math.MaxInt32, Cap: waitResyncPeriod}
+}
+
+func recordEvent(source waitSource, event watch.Event, changes *waitChanges) {
+	if source.resync && event.Type != watch.Bookmark {
+		changes.resyncSource(source.key)
+
+		return
+	}
+
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		if accessor, err := meta.Accessor(event.Object); err == nil {
+			changes.changed(source.key, accessor.GetName(), event.Object)
+		}
+	case watch.Deleted:
+		if accessor, err := meta.Accessor(event.Object); err == nil {
+			changes.changed(source.key, accessor.GetName(), nil)
+		}
+	case watch.Error:
+		changes.resyncSource(source.key)
+	case watch.Bookmark:
+		// Bookmarks only advance the resourceVersion, which is not used as
+		// watches are always restarted with a full resync.
+	}
+}
+
+// waitTargets returns the targets and watches Wait uses for all tracked
+// resources. The order of the targets is the order they are listed in errors.
+func (r *Resources) waitTargets() ([]waitTarget, []waitSource) {
+	clientSet := r.TestClients.ClientSet
+	namespace := r.namespace()
+
+	var (
+		targets []waitTarget
+		sources []waitSource
+	)
+
+	targets, sources = addWaitTargets(targets, sources, "deployment", namespace, r.Deployments,
+		clientSet.AppsV1().Deployments(namespace).Get, clientSet.AppsV1().Deployments(namespace).Watch,
+		func(deployment *appsv1.Deployment) (bool, error) {
+			if r.WaitForRollout {
+				return isDeploymentRolledOut(deployment)
+			}
+
+			return deployment.Status.AvailableReplicas == replicasOf(deployment.Spec.Replicas), nil
+		})
+
+	targets, sources = addWaitTargets(targets, sources, "statefulset", namespace, r.StatefulSets,
+		clientSet.AppsV1().StatefulSets(namespace).Get, clientSet.AppsV1().StatefulSets(namespace).Watch,
+		func(statefulSet *appsv1.StatefulSet) (bool, error) {
+			if r.WaitForRollout {
+				return isStatefulSetRolledOut(statefulSet)
+			}
+
+			return statefulSet.Spec.Replicas != nil &&
+				statefulSet.Status.ReadyReplicas == *statefulSet.Spec.Replicas, nil
+		})
+
+	targets, sources = addWaitTargets(targets, sources, "daemonset", namespace, r.DaemonSets,
+		clientSet.AppsV1().DaemonSets(namespace).Get, clientSet.AppsV1().DaemonSets(namespace).Watch,
+		func(daemonSet *appsv1.DaemonSet) (bool, error) {
+			return isDaemonSetReady(daemonSet), nil
+		})
+
+	targets, sources = addWaitTargets(targets, sources, "service", namespace, r.Services,
+		clientSet.CoreV1().Services(namespace).Get, clientSet.CoreV1().Services(namespace).Watch,
+		func(service *corev1.Service) (bool, error) {
+			return isServiceReady(service), nil
+		})
+
+	targets, sources = addWaitTargets(targets, sources, "job", namespace, r.Jobs,
+		clientSet.BatchV1().Jobs(namespace).Get, clientSet.BatchV1().Jobs(namespace).Watch,
+		func(job *batchv1.Job) (bool, error) {
+			return jobHasOutcome(job, JobSucceeded)
+		})
+
+	for _, tracked := range r.Objects {
+		targets, sources = r.addObjectWaitTarget(targets, sources, tracked)
+	}
+
+	return targets, sources
+}
+
+// addWaitTargets adds a target for each of objects and a watch for their kind.
+func addWaitTargets[T client.Object](targets []waitTarget, sources []waitSource, kind, namespace string,
+	objects []T, get getFunc[T], watchKind watchFunc, ready func(T) (bool, error),
+) ([]waitTarget, []waitSource) {
+	if len(objects) == 0 {
+		return targets, sources
+	}
+
+	for _, obj := range objects {
+		name := obj.GetName()
+
+		targets = append(targets, waitTarget{
+			source:    kind,
+			kind:      kind,
+			namespace: namespace,
+			name:      name,
+			get: func(ctx context.Context) (runtime.Object, error) {
+				return get(ctx, name, metav1.GetOptions{})
+			},
+			ready: func(obj runtime.Object) (bool, error) {
+				typed, ok := obj.(T)
+				if !ok {
+					return false, nil
+				}
+
+				return ready(typed)
+			},
+		})
+	}
+
+	return targets, append(sources, waitSource{key: kind, watch: watchKind})
+}
+
+// addObjectWaitTarget adds a target for an object added through WithObject and
+// a watch for its kind and namespace, if the client supports watches and
+// there is none yet.
+func (r *Resources) addObjectWaitTarget(targets []waitTarget, sources []waitSource,
+	tracked *TrackedObject,
+) ([]waitTarget, []waitSource) {
+	obj := tracked.Object
+	source := objectKind(obj) + "/" + obj.GetNamespace()
+
+	targets = append(targets, waitTarget{
+		source:    source,
+		kind:      objectKind(obj),
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+		get: func(ctx context.Context) (runtime.Object, error) {
+			return r.getUnstructured(ctx, obj)
+		},
+		ready: func(current runtime.Object) (bool, error) {
+			u, ok := current.(*unstructured.Unstructured)
+			if !ok {
+				return false, nil
+			}
+
+			return passesReadinessChecks(u, tracked.ReadinessChecks)
+		},
+	})
+
+	for _, existing := range sources {
+		if existing.key == source {
+			return targets, sources
+		}
+	}
+
+	withWatch, ok := r.TestClients.K8sClient.(client.WithWatch)
+	if !ok {
+		return targets, sources
+	}
+
+	gvk, err := r.TestClients.K8sClient.GroupVersionKindFor(obj)
+	if err != nil {
+		return targets, sources
+	}
+
+	return targets, append(sources, waitSource{
+		key: source,
+		watch: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+
+			return withWatch.Watch(ctx, list, client.InNamespace(obj.GetNamespace()), &client.ListOptions{Raw: &opts})
+		},
+	})
+}
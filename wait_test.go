@@ -0,0 +1,302 @@
+package k8stest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestWaitUsesWatches(t *testing.T) {
+	testClients := NewFakeTestClients()
+
+	clientSet, ok := testClients.ClientSet.(*kubefake.Clientset)
+	if !ok {
+		t.Fatal("Expected a fake clientset")
+	}
+
+	var gets atomic.Int32
+
+	clientSet.PrependReactor("get", "deployments", func(clienttesting.Action) (bool, runtime.Object, error) {
+		gets.Add(1)
+
+		return false, nil, nil
+	})
+
+	resources, err := NewWithClients(t, context.Background(), testClients, WithTestNamespace(),
+		WithSimulator(WithPodReadyDelay(time.Second))).
+		WithDeployment("deployment-wait-1").
+		And().
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(3 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Polling every 100ms would have read the deployment about ten times.
+	if gets.Load() > 1 {
+		t.Errorf("Expected the deployment to be read once and then watched, got %d reads", gets.Load())
+	}
+}
+
+func TestWaitRestartsClosedWatches(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	var (
+		ready   atomic.Bool
+		watches atomic.Int32
+	)
+
+	target := waitTarget{
+		source: "configmap",
+		kind:   "configmap",
+		name:   "config-map-wait-1",
+		get: func(context.Context) (runtime.Object, error) {
+			return &corev1.ConfigMap{}, nil
+		},
+		ready: func(runtime.Object) (bool, error) {
+			return ready.Load(), nil
+		},
+	}
+
+	source := waitSource{
+		key: "configmap",
+		watch: func(context.Context, metav1.ListOptions) (watch.Interface, error) {
+			watcher := watch.NewFake()
+
+			if watches.Add(1) == 1 {
+				// The first watch ends without any event, e.g. because it expired.
+				go func() {
+					time.Sleep(200 * time.Millisecond)
+					watcher.Stop()
+				}()
+
+				return watcher, nil
+			}
+
+			// The target only becomes ready through an event of the reopened watch.
+			go func() {
+				ready.Store(true)
+				watcher.Modify(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config-map-wait-1"}})
+			}()
+
+			return watcher, nil
+		},
+	}
+
+	start := time.Now()
+
+	err := resources.waitFor(2*time.Second, []waitTarget{target}, []waitSource{source})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the closed watch to be opened again, but waiting took %s", elapsed)
+	}
+
+	if watches.Load() < 2 {
+		t.Errorf("Expected the watch to be opened again, got %d watches", watches.Load())
+	}
+}
+
+func TestWaitBacksOffClosedWatches(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	var watches atomic.Int32
+
+	source := waitSource{
+		key: "configmap",
+		watch: func(context.Context, metav1.ListOptions) (watch.Interface, error) {
+			watches.Add(1)
+
+			// The watch fails right away, every time it is opened.
+			return watch.NewEmptyWatch(), nil
+		},
+	}
+
+	err := resources.waitFor(700*time.Millisecond, []waitTarget{constantWaitTarget("configmap",
+		"config-map-wait-11", false)}, []waitSource{source})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the wait to time out, got %v", err)
+	}
+
+	// Opened at 0, then after about 100, 300 and 700 milliseconds.
+	if count := watches.Load(); count < 2 || count > 5 {
+		t.Errorf("Expected the closed watch to be reopened with a backoff, got %d watches", count)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	target := constantWaitTarget("configmap", "config-map-wait-2", false)
+
+	err := resources.waitFor(200*time.Millisecond, []waitTarget{target}, nil)
+	if err == nil || !strings.HasPrefix(err.Error(),
+		"failed to wait for configmap config-map-wait-2: context deadline exceeded\n") {
+		t.Errorf("Expected a timeout error for the configmap, got %v", err)
+	}
+}
+
+// hangingWaitTarget returns a target that is read once and never ready, and
+// whose later reads, e.g. for diagnostics, block until their context is done.
+func hangingWaitTarget(name string) waitTarget {
+	var reads atomic.Int32
+
+	target := constantWaitTarget("configmap", name, false)
+	target.get = func(ctx context.Context) (runtime.Object, error) {
+		if reads.Add(1) > 1 {
+			<-ctx.Done()
+
+			return nil, ctx.Err()
+		}
+
+		return &corev1.ConfigMap{}, nil
+	}
+
+	return target
+}
+
+func TestWaitBoundsDiagnostics(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	start := time.Now()
+
+	err := resources.waitFor(100*time.Millisecond, []waitTarget{hangingWaitTarget("config-map-wait-9")}, nil)
+
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Resources[0].Err == nil {
+		t.Fatalf("Expected a timeout error with a failed diagnosis, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond+diagnosticsTimeout+time.Second {
+		t.Errorf("Expected the diagnostics to give up after %s, took %s", diagnosticsTimeout, elapsed)
+	}
+}
+
+func TestWaitEndsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resources := NewWithClients(t, ctx, NewFakeTestClients())
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+
+	err := resources.waitFor(10*time.Second, []waitTarget{hangingWaitTarget("config-map-wait-10")}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the wait to end with the cancelled context, got %v", err)
+	}
+
+	var timeoutErr *WaitTimeoutError
+	if errors.As(err, &timeoutErr) {
+		t.Errorf("Expected a cancellation not to be reported as a timeout, got %v", err)
+	}
+
+	if expected := "failed to wait for configmap config-map-wait-10: context canceled"; err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the wait to end on cancel, took %s", elapsed)
+	}
+}
+
+func TestWaitListsAllPendingResources(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	targets := []waitTarget{
+		constantWaitTarget("deployment", "deployment-wait-2", true),
+		constantWaitTarget("deployment", "deployment-wait-3", false),
+		constantWaitTarget("statefulset", "statefulset-wait-1", false),
+	}
+
+	err := resources.waitFor(200*time.Millisecond, targets, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the error to wrap context.DeadlineExceeded, got %v", err)
+	}
+
+	expected := "failed to wait for deployment deployment-wait-3; statefulset statefulset-wait-1: " +
+		"context deadline exceeded"
+	if summary, _, _ := strings.Cut(err.Error(), "\n"); summary != expected {
+		t.Errorf("Expected %q, got %q", expected, summary)
+	}
+}
+
+func TestWaitEvaluatesConcurrently(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	var targets []waitTarget
+
+	for i := range 5 {
+		target := constantWaitTarget("configmap", fmt.Sprintf("config-map-wait-%d", i+3), true)
+		target.get = func(context.Context) (runtime.Object, error) {
+			time.Sleep(200 * time.Millisecond)
+
+			return &corev1.ConfigMap{}, nil
+		}
+
+		targets = append(targets, target)
+	}
+
+	start := time.Now()
+
+	err := resources.waitFor(2*time.Second, targets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("Expected the targets to be read concurrently, but waiting took %s", elapsed)
+	}
+}
+
+func TestWaitJoinsEvaluationErrors(t *testing.T) {
+	resources := NewWithClients(t, context.Background(), NewFakeTestClients())
+
+	failing := constantWaitTarget("job", "job-wait-1", false)
+	failing.ready = func(runtime.Object) (bool, error) {
+		return false, ErrJobFailed
+	}
+
+	stuck := constantWaitTarget("deployment", "deployment-wait-4", false)
+	stuck.ready = func(runtime.Object) (bool, error) {
+		return false, ErrProgressDeadlineExceeded
+	}
+
+	err := resources.waitFor(2*time.Second, []waitTarget{failing, stuck}, nil)
+	if !errors.Is(err, ErrJobFailed) || !errors.Is(err, ErrProgressDeadlineExceeded) {
+		t.Errorf("Expected the errors of both targets, got %v", err)
+	}
+}
+
+// constantWaitTarget returns a target whose readiness never changes.
+func constantWaitTarget(kind, name string, ready bool) waitTarget {
+	return waitTarget{
+		source: kind,
+		kind:   kind,
+		name:   name,
+		get: func(context.Context) (runtime.Object, error) {
+			return &corev1.ConfigMap{}, nil
+		},
+		ready: func(runtime.Object) (bool, error) {
+			return ready, nil
+		},
+	}
+}
@@ -0,0 +1,177 @@
+package k8stest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const yamlBufferSize = 4096
+
+// FromYAML decodes all objects of a multi-document YAML or JSON manifest and
+// adds them to r. Kinds with a builder end up in the typed slices, all other
+// kinds are added through WithObject, falling back to the unstructured form
+// for kinds the scheme does not know. The ResourceOptions are applied to every
+// object, and namespaced objects of every kind are placed in the namespace of
+// r like built ones, replacing the namespace given in the manifest.
+func (r *Resources) FromYAML(reader io.Reader) (*Resources, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(reader, yamlBufferSize)
+
+	var objects []*unstructured.Unstructured
+
+	for {
+		obj := &unstructured.Unstructured{}
+
+		err := decoder.Decode(&obj.Object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %w", err)
+		}
+
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		if !obj.IsList() {
+			objects = append(objects, obj)
+
+			continue
+		}
+
+		err = obj.EachListItem(func(item runtime.Object) error {
+			if u, ok := item.(*unstructured.Unstructured); ok {
+				objects = append(objects, u)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest list: %w", err)
+		}
+	}
+
+	var statefulSets []*appsv1.StatefulSet
+
+	for _, obj := range objects {
+		if err := r.addDecoded(obj); err != nil {
+			return nil, err
+		}
+
+		if obj.GetKind() == "StatefulSet" && obj.GroupVersionKind().Group == appsv1.GroupName {
+			statefulSets = append(statefulSets, r.StatefulSets[len(r.StatefulSets)-1])
+		}
+	}
+
+	// Governing services are added last so that Services declared in the
+	// manifest after their StatefulSet take precedence.
+	for _, statefulSet := range statefulSets {
+		r.withGoverningService(statefulSet)
+	}
+
+	return r, nil
+}
+
+// FromFile works like FromYAML for the manifest at path.
+func (r *Resources) FromFile(path string) (*Resources, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	return r.FromYAML(file)
+}
+
+// FromFS works like FromYAML for all manifests in fsys matching pattern, e.g.
+// an embed.FS and "testdata/*.yaml". The manifests are read in lexical order.
+func (r *Resources) FromFS(fsys fs.FS, pattern string) (*Resources, error) {
+	paths, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match manifests: %w", err)
+	}
+
+	for _, path := range paths {
+		file, err := fsys.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open manifest %s: %w", path, err)
+		}
+
+		_, err = r.FromYAML(file)
+		_ = file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load manifest %s: %w", path, err)
+		}
+	}
+
+	return r, nil
+}
+
+// addDecoded converts obj to its Go type if the scheme knows it and adds it to
+// the matching slice.
+//
+//nolint:cyclop // one branch per typed slice
+func (r *Resources) addDecoded(obj *unstructured.Unstructured) error {
+	if r.isNamespaced(obj) {
+		obj.SetNamespace(r.namespace())
+	}
+
+	typed, err := SetupScheme().New(obj.GroupVersionKind())
+	if err != nil {
+		r.WithUnstructured(obj)
+
+		return nil
+	}
+
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	clientObj, ok := typed.(client.Object)
+	if !ok {
+		r.WithUnstructured(obj)
+
+		return nil
+	}
+
+	switch o := typed.(type) {
+	case *appsv1.Deployment:
+		r.Deployments = append(r.Deployments, o)
+	case *appsv1.StatefulSet:
+		r.StatefulSets = append(r.StatefulSets, o)
+	case *appsv1.DaemonSet:
+		r.DaemonSets = append(r.DaemonSets, o)
+	case *batchv1.Job:
+		r.Jobs = append(r.Jobs, o)
+	case *batchv1.CronJob:
+		r.CronJobs = append(r.CronJobs, o)
+	case *corev1.ConfigMap:
+		r.ConfigMaps = append(r.ConfigMaps, o)
+	case *corev1.Secret:
+		r.Secrets = append(r.Secrets, o)
+	case *corev1.Service:
+		r.Services = append(r.Services, o)
+	default:
+		r.WithObject(clientObj)
+
+		return nil
+	}
+
+	r.ApplyOptions(typed)
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package k8stest
+
+import (
+	"context"
+	"embed"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//go:embed testdata/*.yaml
+var testdataFS embed.FS
+
+func TestFromYAML(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: list-secret
+      namespace: elsewhere
+  - apiVersion: v1
+    kind: ServiceAccount
+    metadata:
+      name: list-service-account
+      namespace: elsewhere
+  - apiVersion: example.com/v1
+    kind: Widget
+    metadata:
+      name: list-widget
+      namespace: elsewhere
+  - apiVersion: batch/v1
+    kind: Job
+    metadata:
+      name: list-job
+      namespace: elsewhere
+    spec:
+      template:
+        spec:
+          restartPolicy: Never
+          containers:
+            - name: job
+              image: busybox:latest
+`
+
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients()).
+		FromYAML(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resources.Secrets) != 1 || len(resources.Jobs) != 1 {
+		t.Fatalf("Expected 1 secret and 1 job, got %d and %d", len(resources.Secrets), len(resources.Jobs))
+	}
+
+	if len(resources.Objects) != 2 {
+		t.Fatalf("Expected the service account and the widget as objects, got %d", len(resources.Objects))
+	}
+
+	objects := []client.Object{resources.Secrets[0], resources.Jobs[0], resources.Objects[0].Object,
+		resources.Objects[1].Object}
+
+	for _, obj := range objects {
+		if obj.GetNamespace() != resources.Namespace {
+			t.Errorf("Expected %s %s in namespace %q, got %q", objectKind(obj), obj.GetName(), resources.Namespace,
+				obj.GetNamespace())
+		}
+	}
+}
+
+func TestFromFS(t *testing.T) {
+	annotationOption := func(obj runtime.Object) {
+		if d, ok := obj.(*appsv1.Deployment); ok {
+			d.Annotations = map[string]string{"loaded": "true"}
+		}
+	}
+
+	resources, err := NewFake(t, context.Background(), WithTestNamespace()).
+		WithResourceOption(annotationOption).
+		FromFS(testdataFS, "testdata/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resources.Deployments) != 1 || len(resources.StatefulSets) != 1 || len(resources.ConfigMaps) != 1 {
+		t.Fatalf("Expected 1 deployment, 1 statefulset and 1 configmap, got %d, %d and %d",
+			len(resources.Deployments), len(resources.StatefulSets), len(resources.ConfigMaps))
+	}
+
+	if resources.Deployments[0].Annotations["loaded"] != "true" {
+		t.Errorf("Expected resource options to be applied, got %v", resources.Deployments[0].Annotations)
+	}
+
+	if len(resources.Services) != 1 || resources.Services[0].Spec.ClusterIP != corev1.ClusterIPNone {
+		t.Fatalf("Expected the governing headless service of the statefulset, got %v", resources.Services)
+	}
+
+	if len(resources.Services[0].Spec.Ports) != 1 || resources.Services[0].Spec.Ports[0].Port != 5432 {
+		t.Errorf("Expected port 5432 derived from the statefulset, got %v", resources.Services[0].Spec.Ports)
+	}
+
+	if len(resources.Objects) != 2 {
+		t.Fatalf("Expected the service account and the backup as generic objects, got %d", len(resources.Objects))
+	}
+
+	if _, ok := resources.Objects[0].Object.(*corev1.ServiceAccount); !ok {
+		t.Errorf("Expected a typed service account, got %T", resources.Objects[0].Object)
+	}
+
+	if _, ok := resources.Objects[1].Object.(*unstructured.Unstructured); !ok {
+		t.Errorf("Expected the custom resource to stay unstructured, got %T", resources.Objects[1].Object)
+	}
+
+	// The fake client does not know the Backup kind, so only the known kinds are created.
+	resources.Objects = resources.Objects[:1]
+
+	_, err = resources.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.Wait(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = resources.DeleteAndWait(2 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	resources, err := NewWithClients(t, context.Background(), NewFakeTestClients()).
+		FromFile("testdata/web.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resources.Deployments) != 1 || resources.Deployments[0].Name != "web" {
+		t.Errorf("Expected deployment web, got %v", resources.Deployments)
+	}
+
+	_, err = resources.FromFile("testdata/missing.yaml")
+	if err == nil {
+		t.Error("Expected an error for a missing manifest")
+	}
+}